@@ -0,0 +1,191 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/netes/authentication"
+	"github.com/rancher/netes/cluster"
+	"github.com/rancher/netes/server"
+	"github.com/rancher/netes/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const Prefix = "/netes/v1/"
+
+// Handler serves the netes admin API:
+//
+//	GET  /netes/v1/clusters                       list the servers of all clusters
+//	GET  /netes/v1/clusters/<id>                  show the server of a cluster
+//	POST /netes/v1/clusters/<id>?action=rebuild   replace the server with a new one
+//	POST /netes/v1/clusters/<id>?action=drain     close the server once its requests finish
+//	POST /netes/v1/clusters/<id>?action=evict     close the server immediately
+//	POST /netes/v1/clusters/<id>/nodes/<name>     issue a token for the kubelet of a node
+//	DELETE /netes/v1/clusters/<id>/nodes/<name>   revoke all tokens of a node
+//
+// Requests must carry the configured admin token as a bearer token.
+type Handler struct {
+	token         string
+	serverFactory *server.Factory
+	clusterSource cluster.ClusterSource
+	store         authentication.NodeTokenStore
+}
+
+func New(config *types.GlobalConfig, serverFactory *server.Factory) *Handler {
+	return &Handler{
+		token:         config.AdminToken,
+		serverFactory: serverFactory,
+		clusterSource: config.ClusterSource,
+		store:         config.Store,
+	}
+}
+
+type collection struct {
+	Data []server.ServerInfo `json:"data"`
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if !h.authorized(req) {
+		response(rw, http.StatusUnauthorized, "Invalid or missing admin token")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, Prefix), "/"), "/")
+	if len(parts) == 4 && parts[0] == "clusters" && parts[2] == "nodes" {
+		h.node(rw, req, parts[1], parts[3])
+		return
+	}
+
+	if parts[0] != "clusters" || len(parts) > 2 {
+		response(rw, http.StatusNotFound, "Not found")
+		return
+	}
+
+	if len(parts) == 1 {
+		if req.Method != http.MethodGet {
+			response(rw, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		writeJSON(rw, http.StatusOK, &collection{
+			Data: h.serverFactory.List(),
+		})
+		return
+	}
+
+	clusterID := parts[1]
+	switch req.Method {
+	case http.MethodGet:
+		info := h.serverFactory.Info(clusterID)
+		if info == nil {
+			response(rw, http.StatusNotFound, "No server for cluster "+clusterID)
+			return
+		}
+		writeJSON(rw, http.StatusOK, info)
+	case http.MethodPost:
+		h.action(rw, req, clusterID)
+	default:
+		response(rw, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (h *Handler) action(rw http.ResponseWriter, req *http.Request, clusterID string) {
+	var err error
+	switch req.URL.Query().Get("action") {
+	case "rebuild":
+		err = h.serverFactory.Rebuild(clusterID)
+	case "drain":
+		err = h.serverFactory.Drain(clusterID)
+	case "evict":
+		err = h.serverFactory.Evict(clusterID)
+	default:
+		response(rw, http.StatusBadRequest, "Action must be one of rebuild, drain or evict")
+		return
+	}
+
+	if err != nil {
+		response(rw, actionErrorCode(err), err.Error())
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+type nodeToken struct {
+	Node  string `json:"node"`
+	Token string `json:"token"`
+}
+
+// node issues or revokes the tokens kubelets authenticate with as system:node:<name>
+func (h *Handler) node(rw http.ResponseWriter, req *http.Request, clusterID, nodeName string) {
+	c, err := h.clusterSource.LookupByID(clusterID)
+	if err != nil {
+		response(rw, cluster.ErrorCode(err), err.Error())
+		return
+	}
+	if c == nil || !c.Embedded {
+		response(rw, http.StatusNotFound, "No embedded cluster "+clusterID)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodPost:
+		token, err := authentication.IssueNodeToken(req.Context(), h.store, c.Uuid, nodeName)
+		if err != nil {
+			response(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(rw, http.StatusCreated, &nodeToken{
+			Node:  nodeName,
+			Token: token,
+		})
+	case http.MethodDelete:
+		if _, err := authentication.RevokeNodeTokens(req.Context(), h.store, c.Uuid, nodeName); err != nil {
+			response(rw, http.StatusInternalServerError, err.Error())
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	default:
+		response(rw, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (h *Handler) authorized(req *http.Request) bool {
+	if h.token == "" {
+		return false
+	}
+
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+
+	token := strings.TrimPrefix(auth, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+// actionErrorCode returns the HTTP status for errors of server actions, which are mostly for
+// clusters without a server
+func actionErrorCode(err error) int {
+	switch err := err.(type) {
+	case *cluster.LookupError:
+		return err.Code
+	case *apierrors.StatusError:
+		return int(err.ErrStatus.Code)
+	}
+	return http.StatusNotFound
+}
+
+func response(rw http.ResponseWriter, code int, message string) {
+	writeJSON(rw, code, &client.Error{
+		Status:  int64(code),
+		Message: message,
+	})
+}
+
+func writeJSON(rw http.ResponseWriter, code int, obj interface{}) {
+	rw.Header().Set("content-type", "application/json")
+	rw.WriteHeader(code)
+	json.NewEncoder(rw).Encode(obj)
+}
@@ -0,0 +1,236 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/netes/authentication"
+)
+
+type memoryStore struct {
+	sync.Mutex
+	values map[string][]byte
+}
+
+func (m *memoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.values[key], nil
+}
+
+func (m *memoryStore) Put(ctx context.Context, key string, value []byte) error {
+	m.Lock()
+	defer m.Unlock()
+	m.values[key] = value
+	return nil
+}
+
+func (m *memoryStore) List(ctx context.Context, prefix string) (map[string][]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+	result := map[string][]byte{}
+	for key, value := range m.values {
+		if strings.HasPrefix(key, prefix) {
+			result[key] = value
+		}
+	}
+	return result, nil
+}
+
+func (m *memoryStore) Delete(ctx context.Context, key string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.values, key)
+	return nil
+}
+
+type fakeSource struct {
+	clusters map[string]*client.Cluster
+}
+
+func (f *fakeSource) Lookup(req *http.Request) (*client.Cluster, error) {
+	return nil, nil
+}
+
+func (f *fakeSource) LookupByID(clusterID string) (*client.Cluster, error) {
+	return f.clusters[clusterID], nil
+}
+
+func (f *fakeSource) List() ([]*client.Cluster, error) {
+	return nil, nil
+}
+
+func (f *fakeSource) HealthCheck() error {
+	return nil
+}
+
+func newTestHandler(store *memoryStore) *Handler {
+	embedded := &client.Cluster{Embedded: true}
+	embedded.Id = "1c1"
+	embedded.Uuid = "uuid-1"
+	other := &client.Cluster{Embedded: true}
+	other.Id = "1c2"
+	other.Uuid = "uuid-2"
+	remote := &client.Cluster{K8sClientConfig: &client.K8sClientConfig{Address: "https://remote"}}
+	remote.Id = "1c3"
+
+	return &Handler{
+		token: "admin",
+		clusterSource: &fakeSource{
+			clusters: map[string]*client.Cluster{
+				embedded.Id: embedded,
+				other.Id:    other,
+				remote.Id:   remote,
+			},
+		},
+		store: store,
+	}
+}
+
+func adminRequest(h *Handler, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	return rw
+}
+
+// authenticate authenticates a kubelet request bearing the token in the cluster
+func authenticate(store *memoryStore, clusterUUID, token string) (string, []string) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/nodes/node1", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	// rejected tokens are reported as an invalid bearer token error
+	info, ok, _ := authentication.NewNodeAuthenticator(store, clusterUUID).AuthenticateRequest(req)
+	if !ok {
+		return "", nil
+	}
+	return info.GetName(), info.GetGroups()
+}
+
+func TestNodeTokens(t *testing.T) {
+	store := &memoryStore{values: map[string][]byte{}}
+	h := newTestHandler(store)
+
+	rw := adminRequest(h, http.MethodPost, Prefix+"clusters/1c1/nodes/node1")
+	if rw.Code != http.StatusCreated {
+		t.Fatalf("issuing token responded %d: %s", rw.Code, rw.Body.String())
+	}
+	issued := nodeToken{}
+	if err := json.NewDecoder(rw.Body).Decode(&issued); err != nil {
+		t.Fatal(err)
+	}
+	if issued.Node != "node1" || !strings.HasPrefix(issued.Token, "node-") {
+		t.Fatalf("issued %+v, expected a node- token for node1", issued)
+	}
+
+	tests := []struct {
+		name        string
+		clusterUUID string
+		token       string
+		user        string
+		groups      []string
+	}{
+		{
+			name:        "issued token",
+			clusterUUID: "uuid-1",
+			token:       issued.Token,
+			user:        "system:node:node1",
+			groups:      []string{"system:nodes"},
+		},
+		{
+			name:        "token of another cluster",
+			clusterUUID: "uuid-2",
+			token:       issued.Token,
+		},
+		{
+			name:        "unknown token",
+			clusterUUID: "uuid-1",
+			token:       "node-0123456789abcdef",
+		},
+		{
+			name:        "not a node token",
+			clusterUUID: "uuid-1",
+			token:       strings.TrimPrefix(issued.Token, "node-"),
+		},
+	}
+
+	for _, test := range tests {
+		user, groups := authenticate(store, test.clusterUUID, test.token)
+		if user != test.user || !reflect.DeepEqual(groups, test.groups) {
+			t.Errorf("%s: authenticated as %q %v, expected %q %v", test.name, user, groups, test.user, test.groups)
+		}
+	}
+
+	rw = adminRequest(h, http.MethodDelete, Prefix+"clusters/1c1/nodes/node1")
+	if rw.Code != http.StatusNoContent {
+		t.Fatalf("revoking tokens responded %d: %s", rw.Code, rw.Body.String())
+	}
+	if user, _ := authenticate(store, "uuid-1", issued.Token); user != "" {
+		t.Errorf("revoked token authenticated as %s", user)
+	}
+}
+
+func TestNodeTokenErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		code   int
+	}{
+		{
+			name:   "missing admin token",
+			method: http.MethodPost,
+			path:   Prefix + "clusters/1c1/nodes/node1",
+			code:   http.StatusUnauthorized,
+		},
+		{
+			name:   "invalid node name",
+			method: http.MethodPost,
+			path:   Prefix + "clusters/1c1/nodes/Node_1",
+			token:  "admin",
+			code:   http.StatusBadRequest,
+		},
+		{
+			name:   "remote cluster",
+			method: http.MethodPost,
+			path:   Prefix + "clusters/1c3/nodes/node1",
+			token:  "admin",
+			code:   http.StatusNotFound,
+		},
+		{
+			name:   "missing cluster",
+			method: http.MethodPost,
+			path:   Prefix + "clusters/1c4/nodes/node1",
+			token:  "admin",
+			code:   http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		store := &memoryStore{values: map[string][]byte{}}
+		h := newTestHandler(store)
+
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if test.token != "" {
+			req.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		rw := httptest.NewRecorder()
+		h.ServeHTTP(rw, req)
+
+		if rw.Code != test.code {
+			t.Errorf("%s: responded %d, expected %d", test.name, rw.Code, test.code)
+		}
+		if len(store.values) != 0 {
+			t.Errorf("%s: saved %d tokens, expected none", test.name, len(store.values))
+		}
+	}
+}
@@ -1,40 +1,64 @@
 package authentication
 
 import (
-	"fmt"
+	cryptox509 "crypto/x509"
 	"net/http"
 
+	"github.com/rancher/go-rancher/v3"
 	"github.com/rancher/netes/cluster"
+	"github.com/rancher/netes/types"
 	"k8s.io/apiserver/pkg/authentication/authenticator"
 	"k8s.io/apiserver/pkg/authentication/group"
+	"k8s.io/apiserver/pkg/authentication/request/union"
+	"k8s.io/apiserver/pkg/authentication/request/x509"
 	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/util/cert"
 )
 
+// Authenticator authenticates requests netes makes in process with the identity stored in
+// their context
 type Authenticator struct {
-	clusterLookup *cluster.Lookup
+	groups *GroupMapper
 }
 
-func New(clusterLookup *cluster.Lookup) authenticator.Request {
-	return group.NewAuthenticatedGroupAdder(&Authenticator{
-		clusterLookup: clusterLookup,
-	})
+func New(config *types.GlobalConfig, c *client.Cluster, serviceAccounts authenticator.Request) (authenticator.Request, error) {
+	var authenticators []authenticator.Request
+
+	if config.TLS != nil && config.TLS.ClientCAFile != "" {
+		clientCAs, err := cert.NewPool(config.TLS.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, x509.New(cryptox509.VerifyOptions{
+			Roots:     clientCAs,
+			KeyUsages: []cryptox509.ExtKeyUsage{cryptox509.ExtKeyUsageClientAuth},
+		}, x509.CommonNameUserConversion), authenticator.RequestFunc(forwardedCertUser))
+	}
+
+	groups := NewGroupMapper(config.GroupPrefix, config.RoleGroups)
+	authenticators = append(authenticators,
+		NewNodeAuthenticator(config.Store, c.Uuid),
+		serviceAccounts,
+		NewTokenAuthenticator(groups),
+		&Authenticator{
+			groups: groups,
+		})
+
+	return group.NewAuthenticatedGroupAdder(union.New(authenticators...)), nil
 }
 
 func (a *Authenticator) AuthenticateRequest(req *http.Request) (user.Info, bool, error) {
-	c := cluster.GetCluster(req.Context())
-	if c == nil {
+	identity := cluster.GetIdentity(req.Context())
+	if identity == nil {
 		return nil, false, nil
 	}
 
-	attrs := map[string][]string{}
-	for k, v := range c.Identity.Attributes {
-		attrs[k] = []string{fmt.Sprint(v)}
-	}
+	return a.groups.UserInfo(identity), true, nil
+}
 
-	return &user.DefaultInfo{
-		Name:   c.Identity.Username,
-		UID:    c.Identity.UserId,
-		Groups: []string{"system:masters"},
-		Extra:  attrs,
-	}, true, nil
+// forwardedCertUser authenticates requests forwarded by another replica as the user of the client
+// certificate that replica verified
+func forwardedCertUser(req *http.Request) (user.Info, bool, error) {
+	certUser := cluster.GetCertUser(req.Context())
+	return certUser, certUser != nil, nil
 }
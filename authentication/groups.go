@@ -0,0 +1,50 @@
+package authentication
+
+import (
+	"strings"
+
+	"github.com/rancher/go-rancher/v3"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// roleAttribute is the identity attribute holding the environment role of a Rancher user
+const roleAttribute = "role"
+
+// GroupMapper maps the groups and environment role of Rancher users to Kubernetes groups
+type GroupMapper struct {
+	prefix     string
+	roleGroups map[string][]string
+}
+
+func NewGroupMapper(prefix string, roleGroups map[string][]string) *GroupMapper {
+	return &GroupMapper{
+		prefix:     prefix,
+		roleGroups: roleGroups,
+	}
+}
+
+// UserInfo returns the Kubernetes user of the identity. Groups of the identity are prefixed and
+// dropped if they would be system groups, those can only be granted through the role mappings.
+func (g *GroupMapper) UserInfo(identity *client.ClusterIdentity) user.Info {
+	var groups []string
+	for _, group := range identity.Groups {
+		group = g.prefix + group
+		if strings.HasPrefix(group, "system:") {
+			continue
+		}
+		groups = append(groups, group)
+	}
+	groups = append(groups, g.roleGroups[identity.Attributes[roleAttribute]]...)
+
+	extra := map[string][]string{}
+	for k, v := range identity.Attributes {
+		extra[k] = []string{v}
+	}
+
+	return &user.DefaultInfo{
+		Name:   identity.Username,
+		UID:    identity.UserId,
+		Groups: groups,
+		Extra:  extra,
+	}
+}
@@ -0,0 +1,111 @@
+package authentication
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rancher/go-rancher/v3"
+)
+
+func TestGroupMapperUserInfo(t *testing.T) {
+	roleGroups := map[string][]string{
+		"owner":     {"system:masters"},
+		"member":    {"rancher:members"},
+		"read-only": {"rancher:viewers"},
+	}
+
+	tests := []struct {
+		name     string
+		prefix   string
+		identity client.ClusterIdentity
+		groups   []string
+		extra    map[string][]string
+	}{
+		{
+			name:   "prefixed groups",
+			prefix: "rancher:",
+			identity: client.ClusterIdentity{
+				Groups: []string{"devs", "ops"},
+			},
+			groups: []string{"rancher:devs", "rancher:ops"},
+			extra:  map[string][]string{},
+		},
+		{
+			name: "system group without prefix",
+			identity: client.ClusterIdentity{
+				Groups: []string{"system:masters", "devs", "system:nodes"},
+			},
+			groups: []string{"devs"},
+			extra:  map[string][]string{},
+		},
+		{
+			name:   "system group with prefix",
+			prefix: "rancher:",
+			identity: client.ClusterIdentity{
+				Groups: []string{"system:masters"},
+			},
+			groups: []string{"rancher:system:masters"},
+			extra:  map[string][]string{},
+		},
+		{
+			name:   "prefix making a system group",
+			prefix: "system:",
+			identity: client.ClusterIdentity{
+				Groups: []string{"masters"},
+			},
+			extra: map[string][]string{},
+		},
+		{
+			name:   "role mapped to system group",
+			prefix: "rancher:",
+			identity: client.ClusterIdentity{
+				Groups:     []string{"devs"},
+				Attributes: map[string]string{"role": "owner"},
+			},
+			groups: []string{"rancher:devs", "system:masters"},
+			extra:  map[string][]string{"role": {"owner"}},
+		},
+		{
+			name:   "role mapped to group",
+			prefix: "rancher:",
+			identity: client.ClusterIdentity{
+				Attributes: map[string]string{"role": "read-only", "email": "user@example.com"},
+			},
+			groups: []string{"rancher:viewers"},
+			extra:  map[string][]string{"role": {"read-only"}, "email": {"user@example.com"}},
+		},
+		{
+			name:   "unmapped role",
+			prefix: "rancher:",
+			identity: client.ClusterIdentity{
+				Attributes: map[string]string{"role": "restricted"},
+			},
+			extra: map[string][]string{"role": {"restricted"}},
+		},
+		{
+			name:   "nil attributes",
+			prefix: "rancher:",
+			identity: client.ClusterIdentity{
+				Groups: []string{"devs"},
+			},
+			groups: []string{"rancher:devs"},
+			extra:  map[string][]string{},
+		},
+	}
+
+	for _, test := range tests {
+		test.identity.Username = "user"
+		test.identity.UserId = "1a1"
+
+		info := NewGroupMapper(test.prefix, roleGroups).UserInfo(&test.identity)
+		if info.GetName() != "user" || info.GetUID() != "1a1" {
+			t.Errorf("%s: user %s (%s), expected user (1a1)", test.name, info.GetName(), info.GetUID())
+		}
+		if !reflect.DeepEqual(info.GetGroups(), test.groups) {
+			t.Errorf("%s: groups %v, expected %v", test.name, info.GetGroups(), test.groups)
+		}
+		if !reflect.DeepEqual(info.GetExtra(), test.extra) {
+			t.Errorf("%s: extra %v, expected %v", test.name, info.GetExtra(), test.extra)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package authentication
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/request/bearertoken"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const (
+	nodeTokenPrefix    = "/netes/node-tokens/"
+	nodeTokenHeader    = "node-"
+	nodeCacheSize      = 1000
+	nodeCacheTTL       = time.Minute
+	nodeStoreTimeout   = 5 * time.Second
+	nodeUserNamePrefix = "system:node:"
+	nodesGroup         = "system:nodes"
+)
+
+// NodeTokenStore keeps the hashes of the node tokens issued by netes, implemented by kv.Store
+type NodeTokenStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, value []byte) error
+	List(ctx context.Context, prefix string) (map[string][]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// IssueNodeToken returns a new token authenticating the kubelet of the node as
+// system:node:<nodeName> in the cluster. Only a hash of the token is saved.
+func IssueNodeToken(ctx context.Context, store NodeTokenStore, clusterUUID, nodeName string) (string, error) {
+	if errs := validation.IsDNS1123Subdomain(nodeName); len(errs) > 0 {
+		return "", fmt.Errorf("invalid node name %q: %s", nodeName, strings.Join(errs, ", "))
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := nodeTokenHeader + hex.EncodeToString(secret)
+
+	if err := store.Put(ctx, nodeTokenKey(clusterUUID, token), []byte(nodeName)); err != nil {
+		return "", errors.Wrap(err, "Saving node token")
+	}
+	return token, nil
+}
+
+// RevokeNodeTokens deletes all tokens issued for the node, returning how many were deleted. The
+// tokens may still be accepted for up to a minute by servers which recently authenticated them.
+func RevokeNodeTokens(ctx context.Context, store NodeTokenStore, clusterUUID, nodeName string) (int, error) {
+	tokens, err := store.List(ctx, nodeTokenPrefix+clusterUUID+"/")
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for key, value := range tokens {
+		if string(value) != nodeName {
+			continue
+		}
+		if err := store.Delete(ctx, key); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+func nodeTokenKey(clusterUUID, token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return nodeTokenPrefix + clusterUUID + "/" + hex.EncodeToString(hash[:])
+}
+
+// nodeTokenAuthenticator authenticates the node tokens issued by netes for a cluster
+type nodeTokenAuthenticator struct {
+	store       NodeTokenStore
+	clusterUUID string
+	nodes       *cache.LRUExpireCache
+}
+
+func NewNodeAuthenticator(store NodeTokenStore, clusterUUID string) authenticator.Request {
+	return bearertoken.New(&nodeTokenAuthenticator{
+		store:       store,
+		clusterUUID: clusterUUID,
+		nodes:       cache.NewLRUExpireCache(nodeCacheSize),
+	})
+}
+
+func (n *nodeTokenAuthenticator) AuthenticateToken(token string) (user.Info, bool, error) {
+	if !strings.HasPrefix(token, nodeTokenHeader) {
+		return nil, false, nil
+	}
+
+	key := nodeTokenKey(n.clusterUUID, token)
+	nodeName, ok := n.nodes.Get(key)
+	if !ok {
+		ctx, cancel := context.WithTimeout(context.Background(), nodeStoreTimeout)
+		defer cancel()
+
+		value, err := n.store.Get(ctx, key)
+		if err != nil {
+			return nil, false, err
+		}
+		nodeName = string(value)
+		n.nodes.Add(key, nodeName, nodeCacheTTL)
+	}
+
+	if nodeName == "" {
+		return nil, false, nil
+	}
+
+	return &user.DefaultInfo{
+		Name:   nodeUserNamePrefix + nodeName.(string),
+		Groups: []string{nodesGroup},
+	}, true, nil
+}
@@ -0,0 +1,114 @@
+package authentication
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/netes/clients"
+	"github.com/rancher/netes/store/kv"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/request/bearertoken"
+	"k8s.io/kubernetes/pkg/api/v1"
+	coreinformers "k8s.io/kubernetes/pkg/client/informers/informers_generated/externalversions/core/v1"
+	serviceaccountcontroller "k8s.io/kubernetes/pkg/controller/serviceaccount"
+	"k8s.io/kubernetes/pkg/serviceaccount"
+)
+
+const (
+	serviceAccountKeyPrefix = "/netes/service-account-keys/"
+	serviceAccountKeyBits   = 2048
+)
+
+// ServiceAccountKey returns the key signing the service account tokens of the cluster. The key
+// is generated on first use and kept in the database so tokens stay valid across restarts. When
+// replicas generate a key at the same time only the first one saved is used by all of them.
+//
+// The key is stored unencrypted, just like the secrets of the cluster in the same table. Anyone
+// able to read the database can already read every token the key would let them forge.
+func ServiceAccountKey(ctx context.Context, store *kv.Store, clusterUUID string) (*rsa.PrivateKey, error) {
+	name := serviceAccountKeyPrefix + clusterUUID
+
+	key, err := loadServiceAccountKey(ctx, store, name)
+	if err != nil || key != nil {
+		return key, err
+	}
+
+	key, err = rsa.GenerateKey(rand.Reader, serviceAccountKeyBits)
+	if err != nil {
+		return nil, errors.Wrap(err, "Generating service account key")
+	}
+
+	block := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	err = store.Create(ctx, name, block)
+	if err == kv.ErrExists {
+		return loadServiceAccountKey(ctx, store, name)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Saving service account key")
+	}
+
+	return key, nil
+}
+
+func loadServiceAccountKey(ctx context.Context, store *kv.Store, name string) (*rsa.PrivateKey, error) {
+	value, err := store.Get(ctx, name)
+	if err != nil || value == nil {
+		return nil, errors.Wrap(err, "Loading service account key")
+	}
+
+	block, _ := pem.Decode(value)
+	if block == nil {
+		return nil, errors.Errorf("Invalid service account key %s", name)
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	return key, errors.Wrapf(err, "Invalid service account key %s", name)
+}
+
+// NewServiceAccountAuthenticator authenticates the service account tokens signed with key. The
+// service accounts and secrets of tokens are checked to still exist with the shared informers of
+// the cluster.
+func NewServiceAccountAuthenticator(key *rsa.PrivateKey, clientsetset *clients.ClientSetSet) authenticator.Request {
+	informers := clientsetset.ExternalSharedInformers.Core().V1()
+	getter := &tokenGetter{
+		serviceAccounts: informers.ServiceAccounts(),
+		secrets:         informers.Secrets(),
+		client:          serviceaccountcontroller.NewGetterFromClient(clientsetset.ExternalClient),
+	}
+	getter.serviceAccounts.Informer()
+	getter.secrets.Informer()
+
+	return bearertoken.New(serviceaccount.JWTTokenAuthenticator([]interface{}{&key.PublicKey}, true, getter))
+}
+
+// tokenGetter reads service accounts and secrets from the informers, falling back to the
+// loopback client until the informers have synced
+type tokenGetter struct {
+	serviceAccounts coreinformers.ServiceAccountInformer
+	secrets         coreinformers.SecretInformer
+	client          serviceaccount.ServiceAccountTokenGetter
+}
+
+func (t *tokenGetter) GetServiceAccount(namespace, name string) (*v1.ServiceAccount, error) {
+	serviceAccount, err := t.serviceAccounts.Lister().ServiceAccounts(namespace).Get(name)
+	if apierrors.IsNotFound(err) && !t.serviceAccounts.Informer().HasSynced() {
+		return t.client.GetServiceAccount(namespace, name)
+	}
+	return serviceAccount, err
+}
+
+func (t *tokenGetter) GetSecret(namespace, name string) (*v1.Secret, error) {
+	secret, err := t.secrets.Lister().Secrets(namespace).Get(name)
+	if apierrors.IsNotFound(err) && !t.secrets.Informer().HasSynced() {
+		return t.client.GetSecret(namespace, name)
+	}
+	return secret, err
+}
@@ -0,0 +1,44 @@
+package authentication
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rancher/netes/cluster"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// TokenAuthenticator authenticates callers whose bearer token, basic API key and secret, or token
+// cookie the cluster source accepted when the router resolved the cluster of the request. The
+// identity the cluster source returned is reused rather than checked with Cattle a second time,
+// so a revoked credential stops working once the lookup cached by the cluster source expires.
+type TokenAuthenticator struct {
+	groups *GroupMapper
+}
+
+func NewTokenAuthenticator(groups *GroupMapper) *TokenAuthenticator {
+	return &TokenAuthenticator{
+		groups: groups,
+	}
+}
+
+func (t *TokenAuthenticator) AuthenticateRequest(req *http.Request) (user.Info, bool, error) {
+	if !hasCredentials(req) {
+		return nil, false, nil
+	}
+
+	identity := cluster.GetCallerIdentity(req.Context())
+	if identity == nil || identity.Username == "" {
+		return nil, false, nil
+	}
+	return t.groups.UserInfo(identity), true, nil
+}
+
+func hasCredentials(req *http.Request) bool {
+	auth := req.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") || strings.HasPrefix(auth, "Basic ") {
+		return true
+	}
+	cookie, err := req.Cookie("token")
+	return err == nil && cookie.Value != ""
+}
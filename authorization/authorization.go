@@ -1,16 +1,60 @@
 package authorization
 
 import (
+	"fmt"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/netes/clients"
+	"github.com/rancher/netes/types"
 	authz "k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/authorization/authorizerfactory"
+	"k8s.io/apiserver/pkg/authorization/union"
+	"k8s.io/kubernetes/pkg/kubeapiserver/authorizer/modes"
 )
 
-type authorizer struct {
-}
+// New returns the union of the authorizers configured for the cluster, in order, Node and RBAC
+// by default. Webhook reviews requests with Cattle, or the webhook URL configured for the
+// cluster. Letting every request through with AlwaysAllow has to be enabled explicitly per
+// cluster.
+func New(config *types.GlobalConfig, cluster *client.Cluster, clientsetset *clients.ClientSetSet) (authz.Authorizer, error) {
+	authorizationModes, err := Modes(config, cluster)
+	if err != nil {
+		return nil, err
+	}
+	authorization, _ := config.AuthorizationFor(cluster.Id)
 
-func New() (authz.Authorizer, error) {
-	return &authorizer{}, nil
+	var authorizers []authz.Authorizer
+	for _, mode := range authorizationModes {
+		switch mode {
+		case modes.ModeAlwaysAllow:
+			authorizers = append(authorizers, authorizerfactory.NewAlwaysAllowAuthorizer())
+		case modes.ModeNode:
+			authorizers = append(authorizers, newNode(clientsetset))
+		case modes.ModeRBAC:
+			authorizers = append(authorizers, newRBAC(clientsetset))
+		case modes.ModeWebhook:
+			authorizers = append(authorizers, newWebhook(config, cluster, authorization.WebhookURL))
+		default:
+			return nil, fmt.Errorf("unknown authorization mode %s", mode)
+		}
+	}
+
+	return union.New(authorizers...), nil
 }
 
-func (a *authorizer) Authorize(attr authz.Attributes) (authorized bool, reason string, err error) {
-	return true, "", nil
+// Modes returns the authorization modes of the cluster
+func Modes(config *types.GlobalConfig, cluster *client.Cluster) ([]string, error) {
+	authorization, perCluster := config.AuthorizationFor(cluster.Id)
+	if !perCluster {
+		for _, mode := range authorization.Modes {
+			if mode == modes.ModeAlwaysAllow {
+				return nil, fmt.Errorf("authorization mode %s can only be enabled per cluster", mode)
+			}
+		}
+	}
+
+	if len(authorization.Modes) == 0 {
+		return []string{modes.ModeNode, modes.ModeRBAC}, nil
+	}
+	return authorization.Modes, nil
 }
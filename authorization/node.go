@@ -0,0 +1,86 @@
+package authorization
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/rancher/netes/clients"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/user"
+	authz "k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/client-go/kubernetes"
+	rbacv1beta1 "k8s.io/client-go/pkg/apis/rbac/v1beta1"
+	"k8s.io/kubernetes/pkg/auth/nodeidentifier"
+	"k8s.io/kubernetes/plugin/pkg/auth/authorizer/node"
+	"k8s.io/kubernetes/plugin/pkg/auth/authorizer/rbac/bootstrappolicy"
+)
+
+const (
+	systemNodeBinding    = "system:node"
+	autoUpdateAnnotation = "rbac.authorization.kubernetes.io/autoupdate"
+	nodeBindingInterval  = time.Second
+)
+
+// newNode returns the upstream Node authorizer, which only lets a kubelet read the secrets,
+// config maps and volumes of the pods scheduled to its own node. The graph of pods and volumes
+// is fed by the shared informers of the cluster.
+func newNode(clientsetset *clients.ClientSetSet) authz.Authorizer {
+	informers := clientsetset.InternalSharedInformers.Core().InternalVersion()
+
+	graph := node.NewGraph()
+	node.AddGraphEventHandlers(graph, informers.Pods(), informers.PersistentVolumes())
+
+	return node.NewAuthorizer(graph, nodeidentifier.NewDefaultNodeIdentifier(), bootstrappolicy.NodeRules())
+}
+
+// StartNodesGroupUnbinding removes the system:nodes group from the system:node binding created
+// by the bootstrap policy, which would otherwise let any node read every secret through RBAC.
+// The binding is marked to not be updated so the bootstrap policy does not add the group back
+// on the next start. It is only run for clusters using the Node authorizer, the bootstrap
+// policy itself is shared by every cluster of the process.
+func StartNodesGroupUnbinding(client kubernetes.Interface, stop <-chan struct{}) {
+	go wait.PollUntil(nodeBindingInterval, func() (bool, error) {
+		done, err := unbindNodesGroup(client)
+		if err != nil {
+			glog.Errorf("Failed to remove %s from the %s binding: %v", user.NodesGroup, systemNodeBinding, err)
+		}
+		return done, nil
+	}, stop)
+}
+
+// unbindNodesGroup returns false until the bootstrap policy has created the binding
+func unbindNodesGroup(client kubernetes.Interface) (bool, error) {
+	bindings := client.RbacV1beta1().ClusterRoleBindings()
+
+	binding, err := bindings.Get(systemNodeBinding, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var subjects []rbacv1beta1.Subject
+	for _, subject := range binding.Subjects {
+		if subject.Kind != rbacv1beta1.GroupKind || subject.Name != user.NodesGroup {
+			subjects = append(subjects, subject)
+		}
+	}
+	if len(subjects) == len(binding.Subjects) && binding.Annotations[autoUpdateAnnotation] == "false" {
+		return true, nil
+	}
+
+	if binding.Annotations == nil {
+		binding.Annotations = map[string]string{}
+	}
+	binding.Annotations[autoUpdateAnnotation] = "false"
+	binding.Subjects = subjects
+
+	_, err = bindings.Update(binding)
+	if apierrors.IsConflict(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,54 @@
+package authorization
+
+import (
+	"github.com/rancher/netes/clients"
+	"k8s.io/apimachinery/pkg/labels"
+	rbacapi "k8s.io/kubernetes/pkg/apis/rbac"
+	rbaclisters "k8s.io/kubernetes/pkg/client/listers/rbac/internalversion"
+	"k8s.io/kubernetes/plugin/pkg/auth/authorizer/rbac"
+)
+
+// newRBAC returns the upstream RBAC authorizer reading roles and bindings from the shared
+// informers of the cluster. The bootstrap roles and bindings are created by the post start hook
+// of the rbac API group.
+func newRBAC(clientsetset *clients.ClientSetSet) *rbac.RBACAuthorizer {
+	informers := clientsetset.InternalSharedInformers.Rbac().InternalVersion()
+	return rbac.New(
+		&roleGetter{informers.Roles().Lister()},
+		&roleBindingLister{informers.RoleBindings().Lister()},
+		&clusterRoleGetter{informers.ClusterRoles().Lister()},
+		&clusterRoleBindingLister{informers.ClusterRoleBindings().Lister()},
+	)
+}
+
+type roleGetter struct {
+	lister rbaclisters.RoleLister
+}
+
+func (g *roleGetter) GetRole(namespace, name string) (*rbacapi.Role, error) {
+	return g.lister.Roles(namespace).Get(name)
+}
+
+type roleBindingLister struct {
+	lister rbaclisters.RoleBindingLister
+}
+
+func (l *roleBindingLister) ListRoleBindings(namespace string) ([]*rbacapi.RoleBinding, error) {
+	return l.lister.RoleBindings(namespace).List(labels.Everything())
+}
+
+type clusterRoleGetter struct {
+	lister rbaclisters.ClusterRoleLister
+}
+
+func (g *clusterRoleGetter) GetClusterRole(name string) (*rbacapi.ClusterRole, error) {
+	return g.lister.Get(name)
+}
+
+type clusterRoleBindingLister struct {
+	lister rbaclisters.ClusterRoleBindingLister
+}
+
+func (l *clusterRoleBindingLister) ListClusterRoleBindings() ([]*rbacapi.ClusterRoleBinding, error) {
+	return l.lister.List(labels.Everything())
+}
@@ -0,0 +1,165 @@
+package authorization
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/netes/types"
+	"k8s.io/apimachinery/pkg/util/cache"
+	authz "k8s.io/apiserver/pkg/authorization/authorizer"
+	authorizationv1beta1 "k8s.io/client-go/pkg/apis/authorization/v1beta1"
+)
+
+const (
+	webhookCacheSize       = 1024
+	defaultWebhookAllowTTL = 5 * time.Minute
+	defaultWebhookDenyTTL  = 30 * time.Second
+)
+
+// webhookAuthorizer asks Cattle, or the webhook configured for the cluster, to review requests
+// with a SubjectAccessReview. Allowed and denied reviews are cached separately, so that a burst
+// of denials can not evict the decisions most requests are served from.
+type webhookAuthorizer struct {
+	httpClient http.Client
+	url        string
+	accessKey  string
+	secretKey  string
+	allowTTL   time.Duration
+	denyTTL    time.Duration
+	allowed    *cache.LRUExpireCache
+	denied     *cache.LRUExpireCache
+}
+
+func newWebhook(config *types.GlobalConfig, cluster *client.Cluster, url string) *webhookAuthorizer {
+	w := &webhookAuthorizer{
+		httpClient: http.Client{
+			Timeout: 5 * time.Second,
+		},
+		url:      url,
+		allowTTL: config.WebhookAllowTTL,
+		denyTTL:  config.WebhookDenyTTL,
+		allowed:  cache.NewLRUExpireCache(webhookCacheSize),
+		denied:   cache.NewLRUExpireCache(webhookCacheSize),
+	}
+
+	// only Cattle is sent the netes service credentials
+	if w.url == "" {
+		w.url = strings.TrimSuffix(config.CattleURL, "/") + "/clusters/" + cluster.Id + "?action=subjectaccessreview"
+		w.accessKey = config.CattleAccessKey
+		w.secretKey = config.CattleSecretKey
+	}
+	if w.allowTTL <= 0 {
+		w.allowTTL = defaultWebhookAllowTTL
+	}
+	if w.denyTTL <= 0 {
+		w.denyTTL = defaultWebhookDenyTTL
+	}
+
+	return w
+}
+
+func (w *webhookAuthorizer) Authorize(attr authz.Attributes) (bool, string, error) {
+	review := subjectAccessReview(attr)
+	key, err := json.Marshal(review.Spec)
+	if err != nil {
+		return false, "", err
+	}
+
+	if _, ok := w.allowed.Get(string(key)); ok {
+		return true, "", nil
+	}
+	if reason, ok := w.denied.Get(string(key)); ok {
+		return false, reason.(string), nil
+	}
+
+	status, err := w.review(review)
+	if err != nil {
+		return false, "", err
+	}
+
+	if status.Allowed {
+		w.allowed.Add(string(key), true, w.allowTTL)
+	} else {
+		w.denied.Add(string(key), status.Reason, w.denyTTL)
+	}
+	return status.Allowed, status.Reason, nil
+}
+
+func (w *webhookAuthorizer) review(review *authorizationv1beta1.SubjectAccessReview) (*authorizationv1beta1.SubjectAccessReviewStatus, error) {
+	body, err := json.Marshal(review)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.accessKey != "" {
+		req.SetBasicAuth(w.accessKey, w.secretKey)
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("authorization webhook unavailable: %v", err)
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("authorization webhook responded %d", resp.StatusCode)
+	}
+
+	result := &authorizationv1beta1.SubjectAccessReview{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("parsing authorization webhook response: %v", err)
+	}
+
+	return &result.Status, nil
+}
+
+func subjectAccessReview(attr authz.Attributes) *authorizationv1beta1.SubjectAccessReview {
+	review := &authorizationv1beta1.SubjectAccessReview{}
+	review.APIVersion = authorizationv1beta1.SchemeGroupVersion.String()
+	review.Kind = "SubjectAccessReview"
+
+	if user := attr.GetUser(); user != nil {
+		review.Spec.User = user.GetName()
+		review.Spec.Groups = user.GetGroups()
+		if extra := user.GetExtra(); len(extra) > 0 {
+			review.Spec.Extra = map[string]authorizationv1beta1.ExtraValue{}
+			for k, v := range extra {
+				review.Spec.Extra[k] = authorizationv1beta1.ExtraValue(v)
+			}
+		}
+	}
+
+	if attr.IsResourceRequest() {
+		review.Spec.ResourceAttributes = &authorizationv1beta1.ResourceAttributes{
+			Namespace:   attr.GetNamespace(),
+			Verb:        attr.GetVerb(),
+			Group:       attr.GetAPIGroup(),
+			Version:     attr.GetAPIVersion(),
+			Resource:    attr.GetResource(),
+			Subresource: attr.GetSubresource(),
+			Name:        attr.GetName(),
+		}
+	} else {
+		review.Spec.NonResourceAttributes = &authorizationv1beta1.NonResourceAttributes{
+			Path: attr.GetPath(),
+			Verb: attr.GetVerb(),
+		}
+	}
+
+	return review
+}
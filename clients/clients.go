@@ -2,6 +2,7 @@ package clients
 
 import (
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/rancher/go-rancher/v3"
@@ -27,6 +28,8 @@ type ClientSetSet struct {
 	InternalSharedInformers internalversion.SharedInformerFactory
 
 	ControllerClientBuilder controller.ControllerClientBuilder
+
+	loopback *loopbackTransport
 }
 
 func (c *ClientSetSet) Start(stopCh <-chan struct{}) {
@@ -35,16 +38,30 @@ func (c *ClientSetSet) Start(stopCh <-chan struct{}) {
 	c.InternalSharedInformers.Start(stopCh)
 }
 
+// Serve sends the requests of the loopback clients to the handler of the apiserver
+func (c *ClientSetSet) Serve(handler http.Handler) {
+	c.loopback.serve(handler)
+}
+
+// New returns the loopback clients of the apiserver of a cluster. Their requests are served in
+// memory once Serve is called, authenticated by a token the apiserver maps to system:apiserver.
 func New(cluster *client.Cluster) (*ClientSetSet, error) {
-	var err error
+	token, err := loopbackToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate loopback token: %v", err)
+	}
 
+	loopback := &loopbackTransport{}
 	c := &ClientSetSet{
 		LoopbackClientConfig: rest.Config{
-			Host: "http://localhost:8089/k8s/clusters/" + cluster.Id + "/",
+			Host:        "http://loopback/",
+			BearerToken: token,
+			Transport:   loopback,
 			ContentConfig: rest.ContentConfig{
 				ContentType: "application/vnd.kubernetes.protobuf",
 			},
 		},
+		loopback: loopback,
 	}
 
 	c.Client, err = kubernetes.NewForConfig(&c.LoopbackClientConfig)
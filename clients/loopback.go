@@ -0,0 +1,151 @@
+package clients
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// loopbackTransport serves the requests of the loopback clients in memory with the handler of
+// the apiserver, so they never go through the network, the router or Cattle.
+type loopbackTransport struct {
+	sync.RWMutex
+	handler http.Handler
+}
+
+func (l *loopbackTransport) serve(handler http.Handler) {
+	l.Lock()
+	defer l.Unlock()
+	l.handler = handler
+}
+
+func (l *loopbackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	l.RLock()
+	handler := l.handler
+	l.RUnlock()
+
+	if handler == nil {
+		return nil, fmt.Errorf("apiserver is not serving loopback requests yet")
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	serverReq := req.WithContext(ctx)
+	serverReq.RequestURI = req.URL.RequestURI()
+	serverReq.RemoteAddr = "127.0.0.1:0"
+
+	reader, writer := io.Pipe()
+	rw := &pipeResponseWriter{
+		header:      http.Header{},
+		headerSent:  make(chan struct{}),
+		pipe:        writer,
+		closeNotify: make(chan bool, 1),
+	}
+
+	go func() {
+		<-ctx.Done()
+		rw.closeNotify <- true
+	}()
+
+	go func() {
+		defer rw.finish()
+		handler.ServeHTTP(rw, serverReq)
+	}()
+
+	select {
+	case <-rw.headerSent:
+	case <-req.Context().Done():
+		cancel()
+		reader.Close()
+		return nil, req.Context().Err()
+	}
+
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", rw.code, http.StatusText(rw.code)),
+		StatusCode: rw.code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     rw.sent,
+		Body: &pipeBody{
+			PipeReader: reader,
+			cancel:     cancel,
+		},
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// pipeBody ends the request in the apiserver when the client closes the response body
+type pipeBody struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (p *pipeBody) Close() error {
+	p.cancel()
+	return p.PipeReader.Close()
+}
+
+// pipeResponseWriter streams the response of the apiserver to the client, which is needed for
+// watches to work
+type pipeResponseWriter struct {
+	sync.Mutex
+	header      http.Header
+	sent        http.Header
+	code        int
+	headerSent  chan struct{}
+	pipe        *io.PipeWriter
+	closeNotify chan bool
+}
+
+func (p *pipeResponseWriter) Header() http.Header {
+	return p.header
+}
+
+func (p *pipeResponseWriter) WriteHeader(code int) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.sent != nil {
+		return
+	}
+
+	p.code = code
+	p.sent = http.Header{}
+	for k, v := range p.header {
+		p.sent[k] = append([]string(nil), v...)
+	}
+	close(p.headerSent)
+}
+
+func (p *pipeResponseWriter) Write(b []byte) (int, error) {
+	p.WriteHeader(http.StatusOK)
+	return p.pipe.Write(b)
+}
+
+// Flush is a no-op, writes reach the client as soon as they are read
+func (p *pipeResponseWriter) Flush() {
+}
+
+func (p *pipeResponseWriter) CloseNotify() <-chan bool {
+	return p.closeNotify
+}
+
+func (p *pipeResponseWriter) finish() {
+	p.WriteHeader(http.StatusOK)
+	p.pipe.Close()
+}
+
+// loopbackToken returns a random token which authenticates the loopback clients of a single
+// cluster as system:apiserver
+func loopbackToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,65 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	breakerThreshold = 3
+	breakerCooldown  = 30 * time.Second
+)
+
+// breaker stops calls to a failing dependency for a cooldown period once it has failed
+// breakerThreshold times in a row. After the cooldown the breaker is half-open, a single call
+// is let through to probe the dependency: it closes the breaker if it succeeds and opens it
+// again if it fails. Every call allowed must be followed by success or failure.
+type breaker struct {
+	sync.Mutex
+	failures  int
+	openUntil time.Time
+	halfOpen  bool
+	probing   bool
+}
+
+func (b *breaker) allow() bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if !time.Now().After(b.openUntil) {
+		return false
+	}
+	if !b.halfOpen {
+		return true
+	}
+	if b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.Lock()
+	defer b.Unlock()
+	b.failures = 0
+	b.halfOpen = false
+	b.probing = false
+}
+
+// failure records a failed call and returns true if the breaker opened
+func (b *breaker) failure() bool {
+	b.Lock()
+	defer b.Unlock()
+
+	b.failures++
+	if b.failures < breakerThreshold && !b.halfOpen {
+		return false
+	}
+
+	b.failures = 0
+	b.halfOpen = true
+	b.probing = false
+	b.openUntil = time.Now().Add(breakerCooldown)
+	return true
+}
@@ -0,0 +1,120 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	type call struct {
+		// cooldown, if set, ends the cooldown of the breaker before the call
+		cooldown bool
+		allowed  bool
+		// failed is the outcome of the call, if it was allowed
+		failed bool
+		opened bool
+	}
+
+	tests := []struct {
+		name  string
+		calls []call
+	}{
+		{
+			name: "closed",
+			calls: []call{
+				{allowed: true},
+				{allowed: true, failed: true},
+				{allowed: true},
+				{allowed: true, failed: true},
+				{allowed: true, failed: true},
+				{allowed: true},
+			},
+		},
+		{
+			name: "opens after threshold",
+			calls: []call{
+				{allowed: true, failed: true},
+				{allowed: true, failed: true},
+				{allowed: true, failed: true, opened: true},
+				{allowed: false},
+				{allowed: false},
+			},
+		},
+		{
+			name: "half-open closes on success",
+			calls: []call{
+				{allowed: true, failed: true},
+				{allowed: true, failed: true},
+				{allowed: true, failed: true, opened: true},
+				{allowed: false},
+				{cooldown: true, allowed: true},
+				{allowed: true},
+				{allowed: true, failed: true},
+				{allowed: true, failed: true},
+				{allowed: true},
+			},
+		},
+		{
+			name: "half-open reopens on failure",
+			calls: []call{
+				{allowed: true, failed: true},
+				{allowed: true, failed: true},
+				{allowed: true, failed: true, opened: true},
+				{cooldown: true, allowed: true, failed: true, opened: true},
+				{allowed: false},
+				{cooldown: true, allowed: true},
+				{allowed: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		b := &breaker{}
+		for i, call := range test.calls {
+			if call.cooldown {
+				b.openUntil = time.Now().Add(-time.Second)
+			}
+
+			allowed := b.allow()
+			if allowed != call.allowed {
+				t.Errorf("%s: call %d allowed %v, expected %v", test.name, i, allowed, call.allowed)
+				break
+			}
+			if !allowed {
+				continue
+			}
+
+			opened := false
+			if call.failed {
+				opened = b.failure()
+			} else {
+				b.success()
+			}
+			if opened != call.opened {
+				t.Errorf("%s: call %d opened %v, expected %v", test.name, i, opened, call.opened)
+				break
+			}
+		}
+	}
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	b := &breaker{}
+	for i := 0; i < breakerThreshold; i++ {
+		b.allow()
+		b.failure()
+	}
+	b.openUntil = time.Now().Add(-time.Second)
+
+	if !b.allow() {
+		t.Fatal("expected the probe to be allowed once the cooldown ended")
+	}
+	if b.allow() {
+		t.Fatal("expected calls to be refused while the probe is in flight")
+	}
+
+	b.success()
+	if !b.allow() || !b.allow() {
+		t.Fatal("expected calls to be allowed after the probe succeeded")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/rancher/go-rancher/v3"
+	"k8s.io/apiserver/pkg/authentication/user"
 )
 
 func GetCluster(ctx context.Context) *client.Cluster {
@@ -14,3 +15,40 @@ func GetCluster(ctx context.Context) *client.Cluster {
 func StoreCluster(ctx context.Context, cluster *client.Cluster) context.Context {
 	return context.WithValue(ctx, "cluster", cluster)
 }
+
+// StoreClusterID overrides the cluster id GetClusterID finds in the request
+func StoreClusterID(ctx context.Context, clusterID string) context.Context {
+	return context.WithValue(ctx, "clusterID", clusterID)
+}
+
+// GetIdentity returns the identity stored by netes itself for requests it makes in process
+func GetIdentity(ctx context.Context) *client.ClusterIdentity {
+	identity, _ := ctx.Value("identity").(*client.ClusterIdentity)
+	return identity
+}
+
+func StoreIdentity(ctx context.Context, identity *client.ClusterIdentity) context.Context {
+	return context.WithValue(ctx, "identity", identity)
+}
+
+// GetCallerIdentity returns the identity the cluster source returned for the credentials of the
+// request when the router resolved its cluster
+func GetCallerIdentity(ctx context.Context) *client.ClusterIdentity {
+	identity, _ := ctx.Value("callerIdentity").(*client.ClusterIdentity)
+	return identity
+}
+
+func StoreCallerIdentity(ctx context.Context, identity *client.ClusterIdentity) context.Context {
+	return context.WithValue(ctx, "callerIdentity", identity)
+}
+
+// GetCertUser returns the client certificate user verified by the replica which forwarded the
+// request, as this replica only sees the certificate of the forwarding replica
+func GetCertUser(ctx context.Context) user.Info {
+	certUser, _ := ctx.Value("certUser").(user.Info)
+	return certUser
+}
+
+func StoreCertUser(ctx context.Context, certUser user.Info) context.Context {
+	return context.WithValue(ctx, "certUser", certUser)
+}
@@ -0,0 +1,58 @@
+package cluster
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// LookupError is returned when a cluster can not be resolved for a caller, Code is the HTTP
+// status that should be returned to the caller.
+type LookupError struct {
+	Code    int
+	Message string
+}
+
+func (e *LookupError) Error() string {
+	return e.Message
+}
+
+func NewLookupError(code int, format string, args ...interface{}) *LookupError {
+	return &LookupError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// ErrorCode returns the HTTP status for err, 500 if err is not a LookupError
+func ErrorCode(err error) int {
+	if lookupErr, ok := err.(*LookupError); ok {
+		return lookupErr.Code
+	}
+	return http.StatusInternalServerError
+}
+
+// IsClientError returns true if err is the result of the caller's request rather than a
+// failure of Cattle or netes
+func IsClientError(err error) bool {
+	code := ErrorCode(err)
+	return code >= 400 && code < 500
+}
+
+func responseError(clusterID string, resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return NewLookupError(http.StatusUnauthorized, "Invalid or missing credentials")
+	case resp.StatusCode == http.StatusForbidden:
+		return NewLookupError(http.StatusForbidden, "Access to cluster %s is forbidden", clusterID)
+	case resp.StatusCode == http.StatusNotFound:
+		return NewLookupError(http.StatusNotFound, "Cluster %s not found", clusterID)
+	case resp.StatusCode == http.StatusServiceUnavailable:
+		return NewLookupError(http.StatusServiceUnavailable, "Cattle is unavailable looking up cluster %s", clusterID)
+	default:
+		return NewLookupError(http.StatusBadGateway, "Cattle responded %d looking up cluster %s", resp.StatusCode, clusterID)
+	}
+}
+
+func unavailableError(clusterID string, err error) error {
+	return NewLookupError(http.StatusServiceUnavailable, "Cattle is unavailable looking up cluster %s: %v", clusterID, err)
+}
@@ -0,0 +1,178 @@
+package cluster
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/rancher/go-rancher/v3"
+)
+
+// FileSource serves clusters defined in a local YAML or JSON file so netes can run without
+// Cattle. The file is reloaded whenever it changes.
+//
+//	clusters:
+//	- id: c1
+//	  uuid: 6d1c3c5e-9c3f-4b5a-9f0e-1e2d3c4b5a69
+//	  embedded: true
+//	  k8sServerConfig:
+//	    serviceNetCidr: 10.43.0.0/24
+//	  tokens:
+//	    secret-token:
+//	      username: admin
+//	      userId: 1a1
+type FileSource struct {
+	sync.Mutex
+	path     string
+	modTime  time.Time
+	clusters map[string]*fileCluster
+}
+
+type fileConfig struct {
+	Clusters []*fileCluster `json:"clusters,omitempty"`
+}
+
+type fileCluster struct {
+	client.Cluster
+	Tokens map[string]client.ClusterIdentity `json:"tokens,omitempty"`
+}
+
+func NewFileSource(path string) (*FileSource, error) {
+	f := &FileSource{
+		path: path,
+	}
+	if _, err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FileSource) Lookup(req *http.Request) (*client.Cluster, error) {
+	clusters, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+
+	clusterID := GetClusterID(req)
+	if clusterID == "" {
+		return nil, nil
+	}
+
+	c, ok := clusters[clusterID]
+	if !ok {
+		return nil, NewLookupError(http.StatusNotFound, "Cluster %s not found", clusterID)
+	}
+
+	identity, ok := c.Tokens[getToken(req)]
+	if !ok {
+		return nil, NewLookupError(http.StatusUnauthorized, "Invalid or missing credentials")
+	}
+
+	cluster := c.Cluster
+	cluster.Identity = identity
+	return &cluster, nil
+}
+
+func (f *FileSource) LookupByID(clusterID string) (*client.Cluster, error) {
+	clusters, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := clusters[clusterID]
+	if !ok {
+		return nil, nil
+	}
+
+	cluster := c.Cluster
+	return &cluster, nil
+}
+
+func (f *FileSource) List() ([]*client.Cluster, error) {
+	clusters, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*client.Cluster
+	for _, c := range clusters {
+		cluster := c.Cluster
+		result = append(result, &cluster)
+	}
+	return result, nil
+}
+
+func (f *FileSource) HealthCheck() error {
+	_, err := f.load()
+	return err
+}
+
+func (f *FileSource) load() (map[string]*fileCluster, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	stat, err := os.Stat(f.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Reading clusters file %s", f.path)
+	}
+
+	if f.clusters != nil && stat.ModTime().Equal(f.modTime) {
+		return f.clusters, nil
+	}
+
+	content, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Reading clusters file %s", f.path)
+	}
+
+	config := fileConfig{}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, errors.Wrapf(err, "Parsing clusters file %s", f.path)
+	}
+
+	clusters := map[string]*fileCluster{}
+	for _, c := range config.Clusters {
+		if c.Id == "" {
+			return nil, errors.Errorf("Parsing clusters file %s: cluster without id", f.path)
+		}
+		if c.Uuid == "" {
+			c.Uuid = c.Id
+		}
+		if c.State == "" {
+			c.State = "active"
+		}
+		clusters[c.Id] = c
+	}
+
+	f.clusters = clusters
+	f.modTime = stat.ModTime()
+	return f.clusters, nil
+}
+
+// getToken returns the bearer token, basic auth credentials as "user:password", or token
+// cookie of the request
+func getToken(req *http.Request) string {
+	auth := getAuthorizationHeader(req)
+	if strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+
+	if strings.HasPrefix(auth, "Basic ") {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(auth, "Basic ")))
+		if err == nil {
+			return string(decoded)
+		}
+	}
+
+	if cookie := getTokenCookie(req); cookie != nil {
+		return cookie.Value
+	}
+
+	return ""
+}
@@ -1,6 +1,8 @@
 package cluster
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
 	"io/ioutil"
@@ -8,37 +10,174 @@ import (
 	"strings"
 	"time"
 
-	"github.com/pkg/errors"
 	"github.com/rancher/go-rancher/v3"
+	"k8s.io/apimachinery/pkg/util/cache"
+)
+
+const (
+	credentialCacheSize       = 1000
+	defaultCredentialCacheTTL = 30 * time.Second
 )
 
 type Lookup struct {
-	httpClient http.Client
-	clusterURL string
+	httpClient  http.Client
+	clusterURL  string
+	accessKey   string
+	secretKey   string
+	credentials *cache.LRUExpireCache
+	ttl         time.Duration
 }
 
-func NewLookup(clusterURL string) *Lookup {
+// NewLookup returns a cluster source backed by the Cattle clusters API. Lookups with the
+// credentials of callers are cached for ttl, 30 seconds if it is not set.
+func NewLookup(clusterURL, accessKey, secretKey string, ttl time.Duration) *Lookup {
+	if ttl <= 0 {
+		ttl = defaultCredentialCacheTTL
+	}
 	return &Lookup{
 		httpClient: http.Client{
 			Timeout: 5 * time.Second,
 		},
-		clusterURL: clusterURL,
+		clusterURL:  clusterURL,
+		accessKey:   accessKey,
+		secretKey:   secretKey,
+		credentials: cache.NewLRUExpireCache(credentialCacheSize),
+		ttl:         ttl,
+	}
+}
+
+// LookupByID fetches the cluster using the netes service credentials rather than those of a
+// caller. A nil cluster is returned only if Cattle reports that the cluster does not exist.
+func (c *Lookup) LookupByID(clusterID string) (*client.Cluster, error) {
+	req, err := http.NewRequest("GET", c.clusterURL+"/"+clusterID, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.accessKey, c.secretKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, unavailableError(clusterID, err)
+	}
+	defer close(resp)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
 	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, responseError(clusterID, resp)
+	}
+
+	return parseCluster(resp)
 }
 
+// List returns all clusters visible to the netes service credentials
+func (c *Lookup) List() ([]*client.Cluster, error) {
+	var clusters []*client.Cluster
+
+	next := c.clusterURL
+	for next != "" {
+		req, err := http.NewRequest("GET", next, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.SetBasicAuth(c.accessKey, c.secretKey)
+
+		collection, err := c.list(req)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range collection.Data {
+			clusters = append(clusters, &collection.Data[i])
+		}
+
+		next = ""
+		if collection.Pagination != nil && collection.Pagination.Partial {
+			next = collection.Pagination.Next
+		}
+	}
+
+	return clusters, nil
+}
+
+func (c *Lookup) list(req *http.Request) (*client.ClusterCollection, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, unavailableError("list", err)
+	}
+	defer close(resp)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, responseError("list", resp)
+	}
+
+	collection := &client.ClusterCollection{}
+	if err := json.NewDecoder(resp.Body).Decode(collection); err != nil {
+		return nil, NewLookupError(http.StatusBadGateway, "Parsing clusters response: %v", err)
+	}
+
+	return collection, nil
+}
+
+// HealthCheck returns an error if Cattle can not be reached or is failing
+func (c *Lookup) HealthCheck() error {
+	req, err := http.NewRequest("GET", c.clusterURL+"?limit=1", nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.accessKey, c.secretKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return unavailableError("list", err)
+	}
+	defer close(resp)
+
+	if resp.StatusCode >= 500 {
+		return responseError("list", resp)
+	}
+	return nil
+}
+
+type lookupResult struct {
+	cluster *client.Cluster
+	err     error
+}
+
+// Lookup returns the cluster as seen by the credentials of the caller, including the
+// caller's identity. Results, and rejections of the credentials, are cached per cluster and
+// credential for a short time.
 func (c *Lookup) Lookup(input *http.Request) (*client.Cluster, error) {
 	clusterId := GetClusterID(input)
 	if clusterId == "" {
 		return nil, nil
 	}
 
-	req, err := http.NewRequest("GET", c.clusterURL + "/" + clusterId, nil)
-	if err != nil {
+	key := CredentialKey(clusterId, input)
+	if result, ok := c.credentials.Get(key); ok {
+		return result.(lookupResult).cluster, result.(lookupResult).err
+	}
+
+	cluster, err := c.lookup(clusterId, input)
+	if err != nil && !IsClientError(err) {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", getAuthorizationHeader(req))
+	c.credentials.Add(key, lookupResult{cluster, err}, c.ttl)
+	return cluster, err
+}
+
+func (c *Lookup) lookup(clusterId string, input *http.Request) (*client.Cluster, error) {
+	req, err := http.NewRequest("GET", c.clusterURL+"/"+clusterId, nil)
+	if err != nil {
+		return nil, err
+	}
 
+	if auth := getAuthorizationHeader(input); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
 
 	cookie := getTokenCookie(input)
 	if cookie != nil {
@@ -47,23 +186,31 @@ func (c *Lookup) Lookup(input *http.Request) (*client.Cluster, error) {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, unavailableError(clusterId, err)
 	}
 	defer close(resp)
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return nil, nil
+		return nil, responseError(clusterId, resp)
 	}
 
+	return parseCluster(resp)
+}
+
+func parseCluster(resp *http.Response) (*client.Cluster, error) {
 	cluster := &client.Cluster{}
 	if err := json.NewDecoder(resp.Body).Decode(cluster); err != nil {
-		return nil, errors.Wrap(err, "Parsing clusters response")
+		return nil, NewLookupError(http.StatusBadGateway, "Parsing clusters response: %v", err)
 	}
 
 	return cluster, nil
 }
 
 func GetClusterID(req *http.Request) string {
+	if clusterID, ok := req.Context().Value("clusterID").(string); ok {
+		return clusterID
+	}
+
 	clusterID := req.Header.Get("X-API-Cluster-Id")
 	if clusterID != "" {
 		return clusterID
@@ -77,6 +224,19 @@ func GetClusterID(req *http.Request) string {
 	return ""
 }
 
+// CredentialKey returns a hash identifying the credentials of the request for the cluster
+func CredentialKey(clusterID string, req *http.Request) string {
+	hash := sha256.New()
+	hash.Write([]byte(clusterID))
+	hash.Write([]byte{0})
+	hash.Write([]byte(getAuthorizationHeader(req)))
+	hash.Write([]byte{0})
+	if cookie := getTokenCookie(req); cookie != nil {
+		hash.Write([]byte(cookie.Value))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
 func getAuthorizationHeader(req *http.Request) string {
 	return req.Header.Get("Authorization")
 }
@@ -0,0 +1,91 @@
+package cluster
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rancher/go-rancher/v3"
+)
+
+// MemberLister lists the members of the Rancher environments of a cluster
+type MemberLister interface {
+	Members(clusterID string) ([]client.ProjectMember, error)
+}
+
+// Members reads the members of the projects of clusters from Cattle with the netes service
+// credentials
+type Members struct {
+	httpClient http.Client
+	projectURL string
+	accessKey  string
+	secretKey  string
+}
+
+func NewMembers(cattleURL, accessKey, secretKey string) *Members {
+	return &Members{
+		httpClient: http.Client{
+			Timeout: 5 * time.Second,
+		},
+		projectURL: strings.TrimSuffix(cattleURL, "/") + "/projects",
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+	}
+}
+
+func (m *Members) Members(clusterID string) ([]client.ProjectMember, error) {
+	var members []client.ProjectMember
+
+	next := m.projectURL + "?clusterId=" + url.QueryEscape(clusterID)
+	for next != "" {
+		collection, err := m.list(next, clusterID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, project := range collection.Data {
+			if project.Removed != "" || project.ClusterId != clusterID {
+				continue
+			}
+			for _, member := range project.Members {
+				if member.Removed == "" {
+					members = append(members, member)
+				}
+			}
+		}
+
+		next = ""
+		if collection.Pagination != nil && collection.Pagination.Partial {
+			next = collection.Pagination.Next
+		}
+	}
+
+	return members, nil
+}
+
+func (m *Members) list(projectURL, clusterID string) (*client.ProjectCollection, error) {
+	req, err := http.NewRequest("GET", projectURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(m.accessKey, m.secretKey)
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, unavailableError(clusterID, err)
+	}
+	defer close(resp)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, responseError(clusterID, resp)
+	}
+
+	collection := &client.ProjectCollection{}
+	if err := json.NewDecoder(resp.Body).Decode(collection); err != nil {
+		return nil, NewLookupError(http.StatusBadGateway, "Parsing projects response: %v", err)
+	}
+
+	return collection, nil
+}
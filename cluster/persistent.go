@@ -0,0 +1,191 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/netes/store/kv"
+	"k8s.io/apimachinery/pkg/util/cache"
+)
+
+const (
+	persistentPrefix = "/netes/cluster-cache/"
+	saveInterval     = time.Minute
+	storeTimeout     = 5 * time.Second
+)
+
+// PersistentSource wraps a ClusterSource with a circuit breaker and remembers every successful
+// lookup in the database, so callers keep access to clusters for a grace period while the
+// wrapped source is unavailable, even across restarts of netes.
+type PersistentSource struct {
+	source  ClusterSource
+	store   *kv.Store
+	grace   time.Duration
+	breaker breaker
+	saved   *cache.LRUExpireCache
+}
+
+type persistedLookup struct {
+	Cluster    *client.Cluster `json:"cluster"`
+	Authorized time.Time       `json:"authorized"`
+}
+
+func NewPersistentSource(source ClusterSource, store *kv.Store, grace time.Duration) *PersistentSource {
+	return &PersistentSource{
+		source: source,
+		store:  store,
+		grace:  grace,
+		saved:  cache.NewLRUExpireCache(credentialCacheSize),
+	}
+}
+
+func (p *PersistentSource) Lookup(req *http.Request) (*client.Cluster, error) {
+	clusterID := GetClusterID(req)
+	if clusterID == "" {
+		return nil, nil
+	}
+	key := CredentialKey(clusterID, req)
+
+	var err error
+	if p.breaker.allow() {
+		var cluster *client.Cluster
+		cluster, err = p.source.Lookup(req)
+		if err == nil || IsClientError(err) {
+			p.breaker.success()
+			p.record(key, cluster)
+			return cluster, err
+		}
+		p.failed(err)
+	} else {
+		err = NewLookupError(http.StatusServiceUnavailable, "Cattle is unavailable looking up cluster %s", clusterID)
+	}
+
+	cluster, loadErr := p.load(key)
+	if loadErr != nil {
+		logrus.Errorf("Failed to load cached cluster %s: %v", clusterID, loadErr)
+	}
+	if cluster == nil {
+		return nil, err
+	}
+
+	logrus.Debugf("Serving cached cluster %s while Cattle is unavailable", clusterID)
+	return cluster, nil
+}
+
+func (p *PersistentSource) LookupByID(clusterID string) (*client.Cluster, error) {
+	if !p.breaker.allow() {
+		return nil, NewLookupError(http.StatusServiceUnavailable, "Cattle is unavailable looking up cluster %s", clusterID)
+	}
+
+	cluster, err := p.source.LookupByID(clusterID)
+	if err != nil && !IsClientError(err) {
+		p.failed(err)
+	} else {
+		p.breaker.success()
+	}
+	return cluster, err
+}
+
+func (p *PersistentSource) List() ([]*client.Cluster, error) {
+	if !p.breaker.allow() {
+		return nil, NewLookupError(http.StatusServiceUnavailable, "Cattle is unavailable listing clusters")
+	}
+
+	clusters, err := p.source.List()
+	if err != nil && !IsClientError(err) {
+		p.failed(err)
+	} else {
+		p.breaker.success()
+	}
+	return clusters, err
+}
+
+// HealthCheck reports the health of the wrapped source, a failing source is reported even
+// though cached clusters are still served
+func (p *PersistentSource) HealthCheck() error {
+	return p.source.HealthCheck()
+}
+
+func (p *PersistentSource) failed(err error) {
+	if p.breaker.failure() {
+		logrus.Warnf("Cattle is failing, using cached clusters for %v: %v", breakerCooldown, err)
+	}
+}
+
+// record saves a successful lookup, or forgets the credential if it was rejected. Each
+// credential is written at most once per saveInterval.
+func (p *PersistentSource) record(key string, cluster *client.Cluster) {
+	authorized := cluster != nil
+	if saved, ok := p.saved.Get(key); ok && saved.(bool) == authorized {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	var err error
+	if authorized {
+		var content []byte
+		content, err = json.Marshal(&persistedLookup{
+			Cluster:    withoutCredentials(cluster),
+			Authorized: time.Now(),
+		})
+		if err == nil {
+			err = p.store.Put(ctx, persistentPrefix+key, content)
+		}
+	} else {
+		err = p.store.Delete(ctx, persistentPrefix+key)
+	}
+
+	if err != nil {
+		logrus.Errorf("Failed to update cluster cache: %v", err)
+		return
+	}
+
+	p.saved.Add(key, authorized, saveInterval)
+}
+
+// withoutCredentials returns a copy of the cluster without the credentials netes uses to reach
+// it, which are never persisted. Servers already running keep the credentials they were built
+// with, but remote clusters first served from the cache after a restart can not authenticate
+// to their API until Cattle is available again.
+func withoutCredentials(cluster *client.Cluster) *client.Cluster {
+	result := *cluster
+	result.RegistrationToken = nil
+	if cluster.K8sClientConfig != nil {
+		clientConfig := *cluster.K8sClientConfig
+		clientConfig.BearerToken = ""
+		if address, err := url.Parse(clientConfig.Address); err == nil && address.User != nil {
+			address.User = nil
+			clientConfig.Address = address.String()
+		}
+		result.K8sClientConfig = &clientConfig
+	}
+	return &result
+}
+
+func (p *PersistentSource) load(key string) (*client.Cluster, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	content, err := p.store.Get(ctx, persistentPrefix+key)
+	if err != nil || content == nil {
+		return nil, err
+	}
+
+	lookup := persistedLookup{}
+	if err := json.Unmarshal(content, &lookup); err != nil {
+		return nil, err
+	}
+
+	if time.Since(lookup.Authorized) > p.grace {
+		return nil, nil
+	}
+
+	return lookup.Cluster, nil
+}
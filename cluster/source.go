@@ -0,0 +1,22 @@
+package cluster
+
+import (
+	"net/http"
+
+	"github.com/rancher/go-rancher/v3"
+)
+
+// ClusterSource resolves the clusters netes serves and the identities of their callers
+type ClusterSource interface {
+	// Lookup returns the cluster addressed by the request as seen by the caller, with the
+	// caller's identity set. A nil cluster means the request does not address a cluster, a
+	// LookupError is returned if the caller can not be given the cluster.
+	Lookup(req *http.Request) (*client.Cluster, error)
+	// LookupByID returns the cluster without regard to any caller. A nil cluster means the
+	// cluster does not exist.
+	LookupByID(clusterID string) (*client.Cluster, error)
+	// List returns all clusters without regard to any caller
+	List() ([]*client.Cluster, error)
+	// HealthCheck returns an error if the source can not be reached
+	HealthCheck() error
+}
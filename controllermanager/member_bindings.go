@@ -0,0 +1,139 @@
+package controllermanager
+
+import (
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/rancher/netes/clients"
+	"github.com/rancher/netes/cluster"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	rbacv1beta1 "k8s.io/client-go/pkg/apis/rbac/v1beta1"
+)
+
+const (
+	managedLabel      = "netes.rancher.io/managed"
+	memberBindingName = "netes:members:"
+	memberSyncPeriod  = time.Minute
+)
+
+// DefaultMemberRoles maps the environment roles of Rancher members to the cluster roles they are
+// bound to, restricted members can only read the cluster
+var DefaultMemberRoles = map[string]string{
+	"owner":      "admin",
+	"member":     "edit",
+	"restricted": "view",
+	"readonly":   "view",
+	"read-only":  "view",
+}
+
+// StartMemberBindingController periodically binds the members of the Rancher environments of the
+// cluster to the cluster roles memberRoles maps their environment role to, DefaultMemberRoles if
+// it is empty. The members are bound as the groups of their identities, which Cattle lists as
+// <type>:<id> in the groups of a user, with groupPrefix prepended just like the authenticator does.
+func StartMemberBindingController(clientsetset *clients.ClientSetSet, members cluster.MemberLister, clusterID, groupPrefix string,
+	memberRoles map[string]string, stop <-chan struct{}) {
+	if len(memberRoles) == 0 {
+		memberRoles = DefaultMemberRoles
+	}
+
+	go wait.Until(func() {
+		if err := syncMemberBindings(clientsetset.Client, members, clusterID, groupPrefix, memberRoles); err != nil {
+			glog.Errorf("Failed to sync member bindings of cluster %s: %v", clusterID, err)
+		}
+	}, memberSyncPeriod, stop)
+}
+
+func syncMemberBindings(client kubernetes.Interface, members cluster.MemberLister, clusterID, groupPrefix string, memberRoles map[string]string) error {
+	projectMembers, err := members.Members(clusterID)
+	if err != nil {
+		return err
+	}
+
+	subjects := map[string][]rbacv1beta1.Subject{}
+	seen := map[string]bool{}
+	for _, member := range projectMembers {
+		role := memberRoles[member.Role]
+		if role == "" || member.ExternalId == "" {
+			continue
+		}
+
+		group := groupPrefix + member.ExternalIdType + ":" + member.ExternalId
+		if seen[role+"/"+group] {
+			continue
+		}
+		seen[role+"/"+group] = true
+
+		subjects[role] = append(subjects[role], rbacv1beta1.Subject{
+			Kind:     rbacv1beta1.GroupKind,
+			APIGroup: rbacv1beta1.GroupName,
+			Name:     group,
+		})
+	}
+
+	bindings := client.RbacV1beta1().ClusterRoleBindings()
+	existing, err := bindings.List(metav1.ListOptions{
+		LabelSelector: managedLabel + "=true",
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, binding := range existing.Items {
+		if len(subjects[binding.RoleRef.Name]) == 0 || binding.Name != memberBindingName+binding.RoleRef.Name {
+			if err := bindings.Delete(binding.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+
+	for role, roleSubjects := range subjects {
+		sort.Slice(roleSubjects, func(i, j int) bool {
+			return roleSubjects[i].Name < roleSubjects[j].Name
+		})
+
+		if err := ensureMemberBinding(client, role, roleSubjects); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ensureMemberBinding(client kubernetes.Interface, role string, subjects []rbacv1beta1.Subject) error {
+	bindings := client.RbacV1beta1().ClusterRoleBindings()
+
+	binding, err := bindings.Get(memberBindingName+role, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = bindings.Create(&rbacv1beta1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: memberBindingName + role,
+				Labels: map[string]string{
+					managedLabel: "true",
+				},
+			},
+			RoleRef: rbacv1beta1.RoleRef{
+				APIGroup: rbacv1beta1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     role,
+			},
+			Subjects: subjects,
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(binding.Subjects, subjects) {
+		return nil
+	}
+
+	binding.Subjects = subjects
+	_, err = bindings.Update(binding)
+	return err
+}
@@ -0,0 +1,36 @@
+package controllermanager
+
+import (
+	"crypto/rsa"
+
+	"github.com/rancher/netes/clients"
+	serviceaccountcontroller "k8s.io/kubernetes/pkg/controller/serviceaccount"
+	"k8s.io/kubernetes/pkg/serviceaccount"
+)
+
+// StartServiceAccountControllers creates the default service account of every namespace and
+// the token secrets of all service accounts, signed with key
+func StartServiceAccountControllers(clientsetset *clients.ClientSetSet, key *rsa.PrivateKey, rootCA []byte, stop <-chan struct{}) {
+	informers := clientsetset.ExternalSharedInformers.Core().V1()
+
+	serviceAccounts := serviceaccountcontroller.NewServiceAccountsController(
+		informers.ServiceAccounts(),
+		informers.Namespaces(),
+		clientsetset.ExternalClient,
+		serviceaccountcontroller.DefaultServiceAccountsControllerOptions())
+
+	tokens := serviceaccountcontroller.NewTokensController(
+		informers.ServiceAccounts(),
+		informers.Secrets(),
+		clientsetset.ExternalClient,
+		serviceaccountcontroller.TokensControllerOptions{
+			TokenGenerator: serviceaccount.JWTTokenGenerator(key),
+			RootCA:         rootCA,
+		})
+
+	// start the informers the controllers added
+	clientsetset.Start(stop)
+
+	go serviceAccounts.Run(1, stop)
+	go tokens.Run(1, stop)
+}
@@ -0,0 +1,128 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/rancher/netes/cluster"
+	"github.com/rancher/netes/server"
+	"github.com/rancher/netes/store/kv"
+)
+
+const checkTimeout = 5 * time.Second
+
+type check struct {
+	name string
+	run  func() error
+}
+
+const (
+	HealthzPath = "/healthz"
+	ReadyzPath  = "/readyz"
+	// AliasPrefix is prepended to HealthzPath and ReadyzPath for the aliases served when clusters
+	// are routed by host, as requests for the host of a cluster reach the cluster's own /healthz
+	AliasPrefix = "/netes"
+)
+
+// Handler serves the health and readiness of the netes process in the style of the Kubernetes
+// healthz endpoints. With ?verbose the result of every check is listed, along with the state of
+// the server of every cluster, which does not affect the overall result.
+type Handler struct {
+	checks        []check
+	serverFactory *server.Factory
+}
+
+// Healthz checks the database through the k8s-sql dialect. Cattle is not checked, netes keeps
+// serving cached clusters while it is unavailable so restarting netes would not help.
+func Healthz(store *kv.Store, serverFactory *server.Factory) *Handler {
+	return &Handler{
+		checks: []check{
+			{"database", func() error {
+				ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+				defer cancel()
+				return store.Ping(ctx)
+			}},
+		},
+		serverFactory: serverFactory,
+	}
+}
+
+// Readyz additionally checks the cluster source and waits for prewarming of clusters to complete.
+// Prewarming completes even if some clusters failed to build, those are listed with ?verbose.
+func Readyz(store *kv.Store, clusterSource cluster.ClusterSource, serverFactory *server.Factory, prewarm bool) *Handler {
+	h := Healthz(store, serverFactory)
+	h.checks = append(h.checks, check{"cluster-source", clusterSource.HealthCheck})
+	if prewarm {
+		h.checks = append(h.checks, check{"prewarm", func() error {
+			if !serverFactory.Prewarmed() {
+				return fmt.Errorf("clusters are still being prewarmed")
+			}
+			return nil
+		}})
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	_, verbose := req.URL.Query()["verbose"]
+
+	failed := false
+	output := &bytes.Buffer{}
+	for _, check := range h.checks {
+		if err := check.run(); err != nil {
+			failed = true
+			fmt.Fprintf(output, "[-]%s failed: %v\n", check.name, err)
+		} else {
+			fmt.Fprintf(output, "[+]%s ok\n", check.name)
+		}
+	}
+
+	if verbose {
+		h.writeClusters(output)
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if failed {
+		rw.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(rw, "%scheck failed\n", output.String())
+		return
+	}
+
+	if verbose {
+		fmt.Fprintf(rw, "%scheck passed\n", output.String())
+	} else {
+		fmt.Fprint(rw, "ok")
+	}
+}
+
+// writeClusters lists every cluster as building, ready or failed, with the healthz result of the
+// ready ones
+func (h *Handler) writeClusters(output io.Writer) {
+	states := h.serverFactory.States()
+	healthz := h.serverFactory.Healthz()
+
+	var ids []string
+	for id := range states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		state := states[id]
+		switch {
+		case state.State == server.StateReady && healthz[id] != nil:
+			fmt.Fprintf(output, "[-]cluster/%s ready, healthz failed: %v\n", id, healthz[id])
+		case state.State == server.StateReady:
+			fmt.Fprintf(output, "[+]cluster/%s ready\n", id)
+		case state.Err != nil:
+			fmt.Fprintf(output, "[-]cluster/%s %s: %v\n", id, state.State, state.Err)
+		default:
+			fmt.Fprintf(output, "[-]cluster/%s %s\n", id, state.State)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/rancher/netes/master"
 	"github.com/rancher/netes/store"
@@ -26,11 +27,54 @@ func main() {
 		)
 	}
 
-	err := master.New(&types.GlobalConfig{
+	var tlsConfig *types.TLSConfig
+	if certFile := os.Getenv("NETES_TLS_CERT_FILE"); certFile != "" {
+		tlsConfig = &types.TLSConfig{
+			CertFile:     certFile,
+			KeyFile:      os.Getenv("NETES_TLS_KEY_FILE"),
+			CAFile:       os.Getenv("NETES_TLS_CA_FILE"),
+			ClientCAFile: os.Getenv("NETES_TLS_CLIENT_CA_FILE"),
+		}
+	}
+
+	webhookAllowTTL, err := durationEnv("NETES_WEBHOOK_ALLOW_TTL")
+	if err != nil {
+		fail(err)
+	}
+	webhookDenyTTL, err := durationEnv("NETES_WEBHOOK_DENY_TTL")
+	if err != nil {
+		fail(err)
+	}
+
+	var authorizationFile *types.AuthorizationFile
+	if path := os.Getenv("NETES_AUTHORIZATION_FILE"); path != "" {
+		if authorizationFile, err = types.NewAuthorizationFile(path); err != nil {
+			fail(err)
+		}
+	}
+
+	err = master.New(&types.GlobalConfig{
 		Dialect:    "mysql",
 		DSN:        dsn,
 		CattleURL:  "http://localhost:8081/v3/",
 		ListenAddr: ":8089",
+		TLS:        tlsConfig,
+
+		CattleAccessKey: os.Getenv("CATTLE_ACCESS_KEY"),
+		CattleSecretKey: os.Getenv("CATTLE_SECRET_KEY"),
+		ClustersFile:    os.Getenv("NETES_CLUSTERS_FILE"),
+
+		CattleGracePeriod: 15 * time.Minute,
+
+		PeerAddress: os.Getenv("NETES_PEER_ADDRESS"),
+		PeerID:      os.Getenv("NETES_PEER_ID"),
+
+		AdminToken: os.Getenv("NETES_ADMIN_TOKEN"),
+
+		GroupPrefix: "rancher:",
+
+		ShutdownTimeout: 30 * time.Second,
+
 		AdmissionControllers: []string{
 			"NamespaceLifecycle",
 			"LimitRanger",
@@ -39,10 +83,39 @@ func main() {
 			"DefaultStorageClass",
 			"ResourceQuota",
 			"DefaultTolerationSeconds",
+			"NodeRestriction",
 		},
 		ServiceNetCidr: "10.43.0.0/24",
+		IdleTimeout:    time.Hour,
+
+		AuthorizationFile: authorizationFile,
+		WebhookAllowTTL:   webhookAllowTTL,
+		WebhookDenyTTL:    webhookDenyTTL,
+
+		PrewarmConcurrency: 4,
 	}).Run()
+	if err == nil {
+		return
+	}
+
+	fail(err)
+}
 
+func fail(err error) {
 	fmt.Fprintf(os.Stdout, "Failed to run netes: %v", err)
 	os.Exit(1)
 }
+
+// durationEnv parses the environment variable as a duration, zero if it is not set
+func durationEnv(name string) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", name, err)
+	}
+	return duration, nil
+}
@@ -1,12 +1,21 @@
 package master
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/Sirupsen/logrus"
+	"github.com/rancher/netes/admin"
 	"github.com/rancher/netes/cluster"
+	"github.com/rancher/netes/health"
+	"github.com/rancher/netes/membership"
 	"github.com/rancher/netes/router"
 	"github.com/rancher/netes/server"
+	"github.com/rancher/netes/store/kv"
 	"github.com/rancher/netes/types"
 	"k8s.io/kubernetes/pkg/capabilities"
 )
@@ -20,6 +29,9 @@ func New(c *types.GlobalConfig) *Master {
 type Master struct {
 	config        *types.GlobalConfig
 	serverFactory *server.Factory
+	store         *kv.Store
+	ctx           context.Context
+	cancel        context.CancelFunc
 }
 
 func (m *Master) Run() error {
@@ -33,13 +45,168 @@ func (m *Master) Run() error {
 		PerConnectionBandwidthLimitBytesPerSec: 0,
 	})
 
-	if m.config.Lookup == nil {
-		m.config.Lookup = cluster.NewLookup(m.config.CattleURL + "/clusters")
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	defer m.cancel()
+
+	if err := m.setupClusterSource(); err != nil {
+		return err
+	}
+
+	if err := m.setupMembership(); err != nil {
+		return err
 	}
 
+	store, err := m.kvStore()
+	if err != nil {
+		return err
+	}
+	m.config.Store = store
+
 	m.serverFactory = server.NewFactory(m.config)
-	r := router.New(m.config)
+	m.serverFactory.Watch(m.ctx)
+	if m.config.PrewarmConcurrency > 0 {
+		go func() {
+			if err := m.serverFactory.Prewarm(m.config.PrewarmConcurrency); err != nil {
+				logrus.Errorf("Failed to prewarm clusters: %v", err)
+			}
+		}()
+	}
+
+	r, err := router.New(m.config, m.serverFactory)
+	if err != nil {
+		return err
+	}
+
+	healthz := health.Healthz(store, m.serverFactory)
+	readyz := health.Readyz(store, m.config.ClusterSource, m.serverFactory, m.config.PrewarmConcurrency > 0)
+
+	mux := http.NewServeMux()
+	mux.Handle(health.HealthzPath, r.UnlessHostRouted(healthz))
+	mux.Handle(health.ReadyzPath, r.UnlessHostRouted(readyz))
+	if m.config.ClusterDomain != "" || len(m.config.ClusterHosts) > 0 {
+		mux.Handle(health.AliasPrefix+health.HealthzPath, healthz)
+		mux.Handle(health.AliasPrefix+health.ReadyzPath, readyz)
+	}
+	mux.Handle(admin.Prefix, admin.New(m.config, m.serverFactory))
+	mux.Handle("/", r)
+
+	httpServer := &http.Server{
+		Addr:    m.config.ListenAddr,
+		Handler: mux,
+	}
+
+	if m.config.TLS != nil {
+		if httpServer.TLSConfig, err = tlsConfig(m.config.TLS); err != nil {
+			return err
+		}
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if httpServer.TLSConfig != nil {
+			fmt.Println("Listening with TLS on", m.config.ListenAddr)
+			serveErr <- httpServer.ListenAndServeTLS("", "")
+		} else {
+			fmt.Println("Listening on", m.config.ListenAddr)
+			serveErr <- httpServer.ListenAndServe()
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	select {
+	case err := <-serveErr:
+		return err
+	case sig := <-signals:
+		logrus.Infof("Received %v, shutting down", sig)
+		return m.shutdown(httpServer)
+	}
+}
+
+// shutdown stops accepting connections, ends long running requests cleanly and gives all other
+// requests until the shutdown timeout to finish before closing every cluster server.
+func (m *Master) shutdown(httpServer *http.Server) error {
+	m.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.ShutdownTimeout)
+	defer cancel()
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- httpServer.Shutdown(ctx)
+	}()
+
+	m.serverFactory.ShutdownLongRunning()
+	err := <-shutdownErr
+	if err != nil {
+		logrus.Warnf("Requests still in flight after %v: %v", m.config.ShutdownTimeout, err)
+	}
+
+	m.serverFactory.Close()
+	return nil
+}
+
+func (m *Master) setupClusterSource() error {
+	if m.config.ClusterSource == nil {
+		if m.config.ClustersFile != "" {
+			source, err := cluster.NewFileSource(m.config.ClustersFile)
+			if err != nil {
+				return err
+			}
+			m.config.ClusterSource = source
+		} else {
+			m.config.ClusterSource = cluster.NewLookup(m.config.CattleURL+"/clusters",
+				m.config.CattleAccessKey, m.config.CattleSecretKey, m.config.TokenCacheTTL)
+		}
+	}
+
+	if m.config.Members == nil && m.config.ClustersFile == "" {
+		m.config.Members = cluster.NewMembers(m.config.CattleURL, m.config.CattleAccessKey, m.config.CattleSecretKey)
+	}
+
+	if lookup, ok := m.config.ClusterSource.(*cluster.Lookup); ok && m.config.CattleGracePeriod > 0 {
+		store, err := m.kvStore()
+		if err != nil {
+			return err
+		}
+		m.config.ClusterSource = cluster.NewPersistentSource(lookup, store, m.config.CattleGracePeriod)
+	}
+
+	return nil
+}
+
+func (m *Master) setupMembership() error {
+	if m.config.Membership != nil || m.config.PeerAddress == "" {
+		return nil
+	}
+
+	store, err := m.kvStore()
+	if err != nil {
+		return err
+	}
+
+	id := m.config.PeerID
+	if id == "" {
+		if id, err = os.Hostname(); err != nil {
+			return err
+		}
+	}
+
+	m.config.Membership = membership.New(store, id, m.config.PeerAddress)
+	return m.config.Membership.Start(m.ctx)
+}
+
+func (m *Master) kvStore() (*kv.Store, error) {
+	if m.store != nil {
+		return m.store, nil
+	}
+
+	store, err := kv.New(m.config.Dialect, m.config.DSN)
+	if err != nil {
+		return nil, err
+	}
 
-	fmt.Println("Listening on", m.config.ListenAddr)
-	return http.ListenAndServe(m.config.ListenAddr, r)
+	m.store = store
+	return m.store, nil
 }
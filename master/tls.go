@@ -0,0 +1,52 @@
+package master
+
+import (
+	"crypto/tls"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/netes/types"
+	"k8s.io/client-go/util/cert"
+)
+
+func tlsConfig(config *types.TLSConfig) (*tls.Config, error) {
+	defaultCert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Loading TLS certificate")
+	}
+
+	sniCerts := map[string]*tls.Certificate{}
+	for name, certKey := range config.SNICerts {
+		sniCert, err := tls.LoadX509KeyPair(certKey.CertFile, certKey.KeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Loading TLS certificate for %s", name)
+		}
+		sniCerts[strings.ToLower(name)] = &sniCert
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{defaultCert},
+		MinVersion:   tls.VersionTLS12,
+		// Returning nil falls back to the default certificate
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			name := strings.ToLower(hello.ServerName)
+			if sniCert, ok := sniCerts[name]; ok {
+				return sniCert, nil
+			}
+			if i := strings.Index(name, "."); i > 0 {
+				return sniCerts["*"+name[i:]], nil
+			}
+			return nil, nil
+		},
+	}
+
+	if config.ClientCAFile != "" {
+		tlsConfig.ClientCAs, err = cert.NewPool(config.ClientCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "Loading client CA")
+		}
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
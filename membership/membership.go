@@ -0,0 +1,232 @@
+package membership
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+	"github.com/rancher/netes/store/kv"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	membersPrefix     = "/netes/members/"
+	secretKey         = "/netes/peer-secret"
+	heartbeatInterval = 10 * time.Second
+	memberTTL         = 3 * heartbeatInterval
+	memberExpiry      = 10 * memberTTL
+	storeTimeout      = 5 * time.Second
+)
+
+// Member is a netes replica. Address is the URL other replicas forward requests to.
+type Member struct {
+	ID        string    `json:"id"`
+	Address   string    `json:"address"`
+	Heartbeat time.Time `json:"heartbeat"`
+}
+
+// Membership tracks the live netes replicas through heartbeats in the shared database and
+// assigns every cluster to exactly one of them.
+type Membership struct {
+	sync.RWMutex
+	self   Member
+	store  *kv.Store
+	ring   *ring
+	secret []byte
+}
+
+func New(store *kv.Store, id, address string) *Membership {
+	self := Member{
+		ID:      id,
+		Address: address,
+	}
+
+	return &Membership{
+		self:  self,
+		store: store,
+		ring:  newRing([]*Member{&self}),
+	}
+}
+
+// Start registers this replica and keeps the view of the other replicas up to date until ctx
+// is done, at which point this replica is deregistered.
+func (m *Membership) Start(ctx context.Context) error {
+	secret, err := loadSecret(ctx, m.store)
+	if err != nil {
+		return err
+	}
+	m.secret = secret
+
+	m.sync()
+
+	go func() {
+		time.Sleep(heartbeatInterval)
+		wait.Until(m.sync, heartbeatInterval, ctx.Done())
+
+		deregisterCtx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+		defer cancel()
+		if err := m.store.Delete(deregisterCtx, membersPrefix+m.self.ID); err != nil {
+			logrus.Errorf("Failed to deregister member %s: %v", m.self.ID, err)
+		}
+	}()
+
+	return nil
+}
+
+// loadSecret returns the secret shared by all replicas, generating it if this is the first one
+func loadSecret(ctx context.Context, store *kv.Store) ([]byte, error) {
+	storeCtx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	err := store.Create(storeCtx, secretKey, []byte(hex.EncodeToString(secret)))
+	if err != nil && err != kv.ErrExists {
+		return nil, errors.Wrap(err, "Saving peer secret")
+	}
+
+	value, err := store.Get(storeCtx, secretKey)
+	if err != nil || value == nil {
+		return nil, errors.Wrap(err, "Loading peer secret")
+	}
+	return value, nil
+}
+
+// Sign returns the signature replicas use to prove to each other that they sent the given values
+func (m *Membership) Sign(values ...string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	for _, value := range values {
+		mac.Write([]byte(value))
+		mac.Write([]byte{0})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns true if the signature was made by a replica for the given values
+func (m *Membership) Verify(signature string, values ...string) bool {
+	return len(m.secret) > 0 && hmac.Equal([]byte(signature), []byte(m.Sign(values...)))
+}
+
+// ID returns the id of this replica
+func (m *Membership) ID() string {
+	return m.self.ID
+}
+
+// Owner returns the replica that serves the cluster and whether that is this replica
+func (m *Membership) Owner(clusterID string) (*Member, bool) {
+	m.RLock()
+	defer m.RUnlock()
+
+	owner := m.ring.owner(clusterID)
+	if owner == nil {
+		return &m.self, true
+	}
+	return owner, owner.ID == m.self.ID
+}
+
+// Owns returns true if this replica serves the cluster
+func (m *Membership) Owns(clusterID string) bool {
+	_, self := m.Owner(clusterID)
+	return self
+}
+
+func (m *Membership) sync() {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	if err := m.heartbeat(ctx); err != nil {
+		logrus.Errorf("Failed to send heartbeat for member %s: %v", m.self.ID, err)
+	}
+
+	members, err := m.members(ctx)
+	if err != nil {
+		logrus.Errorf("Failed to list members: %v", err)
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if !sameMembers(members, m.ring) {
+		var ids []string
+		for _, member := range members {
+			ids = append(ids, member.ID)
+		}
+		logrus.Infof("Members changed: %s", strings.Join(ids, ", "))
+	}
+	m.ring = newRing(members)
+}
+
+func (m *Membership) heartbeat(ctx context.Context) error {
+	self := m.self
+	self.Heartbeat = time.Now()
+
+	content, err := json.Marshal(&self)
+	if err != nil {
+		return err
+	}
+	return m.store.Put(ctx, membersPrefix+self.ID, content)
+}
+
+// members returns the live members, always including this replica, and removes members that
+// have been gone for a long time
+func (m *Membership) members(ctx context.Context) ([]*Member, error) {
+	values, err := m.store.List(ctx, membersPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []*Member{&m.self}
+	for key, value := range values {
+		member := &Member{}
+		if err := json.Unmarshal(value, member); err != nil {
+			logrus.Errorf("Failed to parse member %s: %v", key, err)
+			continue
+		}
+
+		age := time.Since(member.Heartbeat)
+		switch {
+		case member.ID == m.self.ID:
+		case age < memberTTL:
+			members = append(members, member)
+		case age > memberExpiry:
+			if err := m.store.Delete(ctx, key); err != nil {
+				logrus.Errorf("Failed to remove expired member %s: %v", member.ID, err)
+			}
+		}
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].ID < members[j].ID
+	})
+	return members, nil
+}
+
+func sameMembers(members []*Member, r *ring) bool {
+	current := map[string]bool{}
+	for _, member := range r.members {
+		current[member.ID] = true
+	}
+
+	if len(current) != len(members) {
+		return false
+	}
+	for _, member := range members {
+		if !current[member.ID] {
+			return false
+		}
+	}
+	return true
+}
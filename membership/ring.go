@@ -0,0 +1,50 @@
+package membership
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+const virtualNodes = 128
+
+// ring is a consistent hash ring, so that only the clusters of a member that joins or leaves
+// change owner
+type ring struct {
+	hashes  []uint32
+	members map[uint32]*Member
+}
+
+func newRing(members []*Member) *ring {
+	r := &ring{
+		members: map[uint32]*Member{},
+	}
+
+	for _, member := range members {
+		for i := 0; i < virtualNodes; i++ {
+			hash := crc32.ChecksumIEEE([]byte(member.ID + "#" + strconv.Itoa(i)))
+			r.hashes = append(r.hashes, hash)
+			r.members[hash] = member
+		}
+	}
+
+	sort.Slice(r.hashes, func(i, j int) bool {
+		return r.hashes[i] < r.hashes[j]
+	})
+	return r
+}
+
+func (r *ring) owner(key string) *Member {
+	if len(r.hashes) == 0 {
+		return nil
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool {
+		return r.hashes[i] >= hash
+	})
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.members[r.hashes[i]]
+}
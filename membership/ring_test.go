@@ -0,0 +1,121 @@
+package membership
+
+import (
+	"fmt"
+	"testing"
+)
+
+func members(ids ...string) []*Member {
+	var result []*Member
+	for _, id := range ids {
+		result = append(result, &Member{ID: id})
+	}
+	return result
+}
+
+func keys(n int) []string {
+	var result []string
+	for i := 0; i < n; i++ {
+		result = append(result, fmt.Sprintf("1c%d", i))
+	}
+	return result
+}
+
+func TestRingOwner(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []*Member
+		owners  []string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "single member",
+			members: members("a"),
+			owners:  []string{"a"},
+		},
+		{
+			name:    "all members own keys",
+			members: members("a", "b", "c"),
+			owners:  []string{"a", "b", "c"},
+		},
+	}
+
+	for _, test := range tests {
+		r := newRing(test.members)
+		owners := map[string]bool{}
+		for _, key := range keys(1000) {
+			owner := r.owner(key)
+			if len(test.members) == 0 {
+				if owner != nil {
+					t.Errorf("%s: %s owned by %s, expected no owner", test.name, key, owner.ID)
+				}
+				continue
+			}
+			if owner == nil {
+				t.Errorf("%s: %s has no owner", test.name, key)
+				continue
+			}
+			owners[owner.ID] = true
+		}
+
+		if len(owners) != len(test.owners) {
+			t.Errorf("%s: owners %v, expected %v", test.name, owners, test.owners)
+		}
+		for _, id := range test.owners {
+			if !owners[id] {
+				t.Errorf("%s: %s owns no keys", test.name, id)
+			}
+		}
+	}
+}
+
+func TestRingMembershipChange(t *testing.T) {
+	tests := []struct {
+		name   string
+		before []*Member
+		after  []*Member
+		// moved is the only member keys may move from or to
+		moved string
+	}{
+		{
+			name:   "unchanged",
+			before: members("a", "b", "c"),
+			after:  members("c", "a", "b"),
+		},
+		{
+			name:   "member joins",
+			before: members("a", "b"),
+			after:  members("a", "b", "c"),
+			moved:  "c",
+		},
+		{
+			name:   "member leaves",
+			before: members("a", "b", "c"),
+			after:  members("a", "c"),
+			moved:  "b",
+		},
+	}
+
+	for _, test := range tests {
+		before, after := newRing(test.before), newRing(test.after)
+		moved := 0
+		for _, key := range keys(1000) {
+			from, to := before.owner(key).ID, after.owner(key).ID
+			if from == to {
+				continue
+			}
+			moved++
+			if test.moved == "" {
+				t.Errorf("%s: %s moved from %s to %s", test.name, key, from, to)
+			} else if from != test.moved && to != test.moved {
+				t.Errorf("%s: %s moved from %s to %s, expected only keys of %s to move", test.name, key, from, to, test.moved)
+			}
+		}
+
+		if test.moved != "" && moved == 0 {
+			t.Errorf("%s: no keys moved", test.name)
+		}
+	}
+}
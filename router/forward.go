@@ -0,0 +1,265 @@
+package router
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/netes/cluster"
+	"github.com/rancher/netes/membership"
+	"github.com/rancher/netes/status"
+	"github.com/rancher/netes/types"
+	"k8s.io/apiserver/pkg/authentication/request/x509"
+	"k8s.io/apiserver/pkg/authentication/user"
+	genericrest "k8s.io/apiserver/pkg/registry/generic/rest"
+	"k8s.io/client-go/util/cert"
+)
+
+const (
+	forwardedHeader          = "X-Netes-Forwarded-By"
+	forwardedAtHeader        = "X-Netes-Forwarded-At"
+	forwardedUserHeader      = "X-Netes-Forwarded-User"
+	forwardedGroupHeader     = "X-Netes-Forwarded-Group"
+	forwardedNonceHeader     = "X-Netes-Forwarded-Nonce"
+	forwardedSignatureHeader = "X-Netes-Forwarded-Signature"
+
+	forwardedHeaderPrefix = "X-Netes-Forwarded-"
+	forwardedMaxAge       = time.Minute
+	notOwnerRetryAfter    = 1
+)
+
+// replicas is the view of the netes replicas requests are forwarded between, implemented by
+// membership.Membership
+type replicas interface {
+	ID() string
+	Owner(clusterID string) (*membership.Member, bool)
+	Sign(values ...string) string
+	Verify(signature string, values ...string) bool
+}
+
+// forward proxies the request, including upgraded connections, to the replica that owns the
+// cluster. Requests forwarded by another replica are never forwarded again to avoid loops, they
+// are refused with 503 while the replicas disagree about the owner.
+func (r *Router) forward(rw http.ResponseWriter, req *http.Request, clusterID string, forwarded bool) bool {
+	if r.membership == nil || clusterID == "" {
+		return false
+	}
+
+	owner, self := r.membership.Owner(clusterID)
+	if self {
+		return false
+	}
+
+	if forwarded {
+		status.Write(rw, status.ServiceUnavailable("Cluster "+clusterID+" is moving between replicas", notOwnerRetryAfter))
+		return true
+	}
+
+	location, err := peerLocation(owner, req)
+	if err != nil {
+		response(rw, req, http.StatusInternalServerError, err.Error())
+		return true
+	}
+
+	if err := r.signForwarded(req, owner.ID); err != nil {
+		response(rw, req, http.StatusInternalServerError, err.Error())
+		return true
+	}
+	handler := genericrest.NewUpgradeAwareProxyHandler(location, r.peerTransport, false, false, &responder{rw, req})
+	handler.ServeHTTP(rw, req)
+	return true
+}
+
+// signForwarded adds the headers proving to the owner that the request was forwarded by a peer,
+// along with the user of the client certificate this replica verified, if any. The signature
+// covers the method, path and query of the request and a nonce the owner accepts only once.
+// The body is not signed, it is protected by the TLS connection between the replicas.
+func (r *Router) signForwarded(req *http.Request, ownerID string) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	at := strconv.FormatInt(time.Now().Unix(), 10)
+	forwarded := forwardedRequest{
+		by:    ownerID,
+		at:    at,
+		nonce: hex.EncodeToString(nonce),
+	}
+	if certUser := clientCertUser(req); certUser != nil {
+		forwarded.user, forwarded.groups = certUser.GetName(), certUser.GetGroups()
+	}
+
+	req.Header.Set(forwardedHeader, forwarded.by)
+	req.Header.Set(forwardedAtHeader, forwarded.at)
+	req.Header.Set(forwardedNonceHeader, forwarded.nonce)
+	if forwarded.user != "" {
+		req.Header.Set(forwardedUserHeader, forwarded.user)
+		for _, group := range forwarded.groups {
+			req.Header.Add(forwardedGroupHeader, group)
+		}
+	}
+	req.Header.Set(forwardedSignatureHeader, r.membership.Sign(forwarded.values(req)...))
+	return nil
+}
+
+// verifyForwarded removes the forwarding headers from the request, returning true if they were
+// signed by a peer for this replica. The client certificate user verified by the peer is stored
+// in the context of the returned request.
+func (r *Router) verifyForwarded(req *http.Request) (*http.Request, bool) {
+	forwarded := forwardedRequest{
+		by:     req.Header.Get(forwardedHeader),
+		at:     req.Header.Get(forwardedAtHeader),
+		nonce:  req.Header.Get(forwardedNonceHeader),
+		user:   req.Header.Get(forwardedUserHeader),
+		groups: req.Header[http.CanonicalHeaderKey(forwardedGroupHeader)],
+	}
+	signature := req.Header.Get(forwardedSignatureHeader)
+
+	for name := range req.Header {
+		if strings.HasPrefix(name, forwardedHeaderPrefix) {
+			req.Header.Del(name)
+		}
+	}
+
+	if r.membership == nil || signature == "" || forwarded.nonce == "" || forwarded.by != r.membership.ID() {
+		return req, false
+	}
+
+	sent, err := strconv.ParseInt(forwarded.at, 10, 64)
+	if err != nil || time.Since(time.Unix(sent, 0)) > forwardedMaxAge || time.Until(time.Unix(sent, 0)) > forwardedMaxAge {
+		return req, false
+	}
+
+	if !r.membership.Verify(signature, forwarded.values(req)...) || !r.nonces.use(forwarded.nonce) {
+		return req, false
+	}
+
+	if forwarded.user != "" {
+		req = req.WithContext(cluster.StoreCertUser(req.Context(), &user.DefaultInfo{
+			Name:   forwarded.user,
+			Groups: forwarded.groups,
+		}))
+	}
+	return req, true
+}
+
+// forwardedRequest is what a replica forwarding a request signs for the owner
+type forwardedRequest struct {
+	by     string
+	at     string
+	nonce  string
+	user   string
+	groups []string
+}
+
+func (f *forwardedRequest) values(req *http.Request) []string {
+	return append([]string{
+		f.by,
+		f.at,
+		f.nonce,
+		req.Method,
+		strings.TrimSuffix(req.URL.Path, "/"),
+		req.URL.RawQuery,
+		f.user,
+	}, f.groups...)
+}
+
+// nonces remembers the nonces of forwarded requests for as long as their timestamp is accepted,
+// so a captured request can not be replayed
+type nonces struct {
+	sync.Mutex
+	used   map[string]time.Time
+	pruned time.Time
+}
+
+func newNonces() *nonces {
+	return &nonces{
+		used:   map[string]time.Time{},
+		pruned: time.Now(),
+	}
+}
+
+// use returns false if the nonce was already used
+func (n *nonces) use(nonce string) bool {
+	n.Lock()
+	defer n.Unlock()
+
+	now := time.Now()
+	if now.Sub(n.pruned) > forwardedMaxAge {
+		for used, expires := range n.used {
+			if now.After(expires) {
+				delete(n.used, used)
+			}
+		}
+		n.pruned = now
+	}
+
+	if _, ok := n.used[nonce]; ok {
+		return false
+	}
+	// timestamps are accepted up to forwardedMaxAge in the past and in the future
+	n.used[nonce] = now.Add(2 * forwardedMaxAge)
+	return true
+}
+
+// clientCertUser returns the user of the verified client certificate of the caller, which may
+// have been verified by the replica that forwarded the request
+func clientCertUser(req *http.Request) user.Info {
+	if certUser := cluster.GetCertUser(req.Context()); certUser != nil {
+		return certUser
+	}
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+
+	certUser, ok, err := x509.CommonNameUserConversion(req.TLS.VerifiedChains[0])
+	if err != nil || !ok {
+		return nil
+	}
+	return certUser
+}
+
+// newPeerTransport returns the transport used to forward requests to other replicas, trusting
+// the CA netes serves TLS with
+func newPeerTransport(config *types.GlobalConfig) (*http.Transport, error) {
+	transport := &http.Transport{}
+	if config.TLS == nil {
+		return transport, nil
+	}
+
+	rootCAs, err := cert.NewPool(types.FirstNotEmpty(config.TLS.CAFile, config.TLS.CertFile))
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs: rootCAs,
+	}
+	return transport, nil
+}
+
+func peerLocation(owner *membership.Member, req *http.Request) (*url.URL, error) {
+	location, err := url.Parse(owner.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	location.Path = req.URL.Path
+	location.RawQuery = req.URL.RawQuery
+	return location, nil
+}
+
+type responder struct {
+	rw  http.ResponseWriter
+	req *http.Request
+}
+
+func (r *responder) Error(err error) {
+	response(r.rw, r.req, http.StatusBadGateway, err.Error())
+}
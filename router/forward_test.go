@@ -0,0 +1,226 @@
+package router
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rancher/netes/cluster"
+	"github.com/rancher/netes/membership"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+type fakeReplicas struct {
+	id     string
+	secret string
+}
+
+func (f *fakeReplicas) ID() string {
+	return f.id
+}
+
+func (f *fakeReplicas) Owner(clusterID string) (*membership.Member, bool) {
+	return &membership.Member{ID: f.id}, true
+}
+
+func (f *fakeReplicas) Sign(values ...string) string {
+	mac := hmac.New(sha256.New, []byte(f.secret))
+	for _, value := range values {
+		mac.Write([]byte(value))
+		mac.Write([]byte{0})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (f *fakeReplicas) Verify(signature string, values ...string) bool {
+	return hmac.Equal([]byte(signature), []byte(f.Sign(values...)))
+}
+
+func newReplicaRouter(id string) *Router {
+	return &Router{
+		membership: &fakeReplicas{id: id, secret: "secret"},
+		nonces:     newNonces(),
+	}
+}
+
+func newForwardedRequest(t *testing.T, from *Router) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/k8s/clusters/c1/api/v1/namespaces/default/pods?limit=10", nil)
+	req = req.WithContext(cluster.StoreCertUser(req.Context(), &user.DefaultInfo{
+		Name:   "system:node:n1",
+		Groups: []string{"system:nodes"},
+	}))
+	if err := from.signForwarded(req, "r2"); err != nil {
+		t.Fatal(err)
+	}
+	// the owner only learns the cert user from the forwarding headers
+	return req.WithContext(httptest.NewRequest(http.MethodGet, "/", nil).Context())
+}
+
+// resign signs the forwarding headers of the request again as the given replica
+func resign(from *Router, req *http.Request) {
+	forwarded := forwardedRequest{
+		by:     req.Header.Get(forwardedHeader),
+		at:     req.Header.Get(forwardedAtHeader),
+		nonce:  req.Header.Get(forwardedNonceHeader),
+		user:   req.Header.Get(forwardedUserHeader),
+		groups: req.Header[forwardedGroupHeader],
+	}
+	req.Header.Set(forwardedSignatureHeader, from.membership.Sign(forwarded.values(req)...))
+}
+
+func TestVerifyForwarded(t *testing.T) {
+	r1, r2 := newReplicaRouter("r1"), newReplicaRouter("r2")
+	outsider := &Router{membership: &fakeReplicas{id: "r1", secret: "other"}}
+
+	tests := []struct {
+		name     string
+		tamper   func(req *http.Request)
+		verified bool
+	}{
+		{
+			name:     "unchanged",
+			verified: true,
+		},
+		{
+			name: "method",
+			tamper: func(req *http.Request) {
+				req.Method = http.MethodDelete
+			},
+		},
+		{
+			name: "path",
+			tamper: func(req *http.Request) {
+				req.URL.Path = "/k8s/clusters/c1/api/v1/namespaces/default/secrets"
+			},
+		},
+		{
+			name: "query",
+			tamper: func(req *http.Request) {
+				req.URL.RawQuery = "limit=10&watch=true"
+			},
+		},
+		{
+			name: "user",
+			tamper: func(req *http.Request) {
+				req.Header.Set(forwardedUserHeader, "admin")
+			},
+		},
+		{
+			name: "group",
+			tamper: func(req *http.Request) {
+				req.Header.Add(forwardedGroupHeader, "system:masters")
+			},
+		},
+		{
+			name: "nonce",
+			tamper: func(req *http.Request) {
+				req.Header.Set(forwardedNonceHeader, "0000")
+			},
+		},
+		{
+			name: "timestamp",
+			tamper: func(req *http.Request) {
+				req.Header.Set(forwardedAtHeader, strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10))
+			},
+		},
+		{
+			name: "expired",
+			tamper: func(req *http.Request) {
+				req.Header.Set(forwardedAtHeader, strconv.FormatInt(time.Now().Add(-2*forwardedMaxAge).Unix(), 10))
+				resign(r1, req)
+			},
+		},
+		{
+			name: "signed for another replica",
+			tamper: func(req *http.Request) {
+				req.Header.Set(forwardedHeader, "r3")
+				resign(r1, req)
+			},
+		},
+		{
+			name: "signed without the secret",
+			tamper: func(req *http.Request) {
+				resign(outsider, req)
+			},
+		},
+		{
+			name: "missing signature",
+			tamper: func(req *http.Request) {
+				req.Header.Del(forwardedSignatureHeader)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		req := newForwardedRequest(t, r1)
+		if test.tamper != nil {
+			test.tamper(req)
+		}
+
+		req, verified := r2.verifyForwarded(req)
+		if verified != test.verified {
+			t.Errorf("%s: verified %v, expected %v", test.name, verified, test.verified)
+		}
+
+		for name := range req.Header {
+			if strings.HasPrefix(name, forwardedHeaderPrefix) {
+				t.Errorf("%s: forwarding header %s was not removed", test.name, name)
+			}
+		}
+
+		certUser := cluster.GetCertUser(req.Context())
+		if !test.verified {
+			if certUser != nil {
+				t.Errorf("%s: unverified request has cert user %s", test.name, certUser.GetName())
+			}
+			continue
+		}
+		if certUser == nil || certUser.GetName() != "system:node:n1" || !reflect.DeepEqual(certUser.GetGroups(), []string{"system:nodes"}) {
+			t.Errorf("%s: cert user %v, expected system:node:n1 in system:nodes", test.name, certUser)
+		}
+	}
+}
+
+func TestVerifyForwardedReplay(t *testing.T) {
+	r1, r2 := newReplicaRouter("r1"), newReplicaRouter("r2")
+
+	req := newForwardedRequest(t, r1)
+	replay := req.WithContext(req.Context())
+	replay.Header = http.Header{}
+	for name, values := range req.Header {
+		replay.Header[name] = append([]string(nil), values...)
+	}
+
+	if _, verified := r2.verifyForwarded(req); !verified {
+		t.Fatal("expected the forwarded request to be verified")
+	}
+	if _, verified := r2.verifyForwarded(replay); verified {
+		t.Fatal("expected the replayed request to be rejected")
+	}
+}
+
+func TestVerifyForwardedNotForwarded(t *testing.T) {
+	r2 := newReplicaRouter("r2")
+
+	req := httptest.NewRequest(http.MethodGet, "/k8s/clusters/c1/api", nil)
+	req.Header.Set(forwardedUserHeader, "admin")
+	req.Header.Set(forwardedGroupHeader, "system:masters")
+
+	req, verified := r2.verifyForwarded(req)
+	if verified {
+		t.Error("expected a request without signature to not be verified")
+	}
+	if len(req.Header) != 0 {
+		t.Errorf("expected forwarding headers to be removed, found %v", req.Header)
+	}
+	if certUser := cluster.GetCertUser(req.Context()); certUser != nil {
+		t.Errorf("expected no cert user, found %s", certUser.GetName())
+	}
+}
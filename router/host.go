@@ -0,0 +1,53 @@
+package router
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// hostClusterID returns the cluster selected by the Host header of the request. Requests routed
+// by host are passed to the cluster with their path unchanged.
+func (r *Router) hostClusterID(req *http.Request) string {
+	if r.clusterDomain == "" && len(r.clusterHosts) == 0 {
+		return ""
+	}
+
+	host := strings.ToLower(req.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if clusterID, ok := r.clusterHosts[host]; ok {
+		return clusterID
+	}
+
+	if r.clusterDomain != "" && strings.HasSuffix(host, "."+r.clusterDomain) {
+		clusterID := strings.TrimSuffix(host, "."+r.clusterDomain)
+		if clusterID != "" && !strings.Contains(clusterID, ".") {
+			return clusterID
+		}
+	}
+
+	return ""
+}
+
+// UnlessHostRouted returns a handler serving requests with handler, except for requests routed to
+// a cluster by host which are served by the router with their paths unchanged
+func (r *Router) UnlessHostRouted(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-API-Cluster-Id") == "" && r.hostClusterID(req) != "" {
+			r.ServeHTTP(rw, req)
+			return
+		}
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+func lowerKeys(input map[string]string) map[string]string {
+	result := map[string]string{}
+	for k, v := range input {
+		result[strings.ToLower(k)] = v
+	}
+	return result
+}
@@ -0,0 +1,191 @@
+package router
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/netes/cluster"
+	"github.com/rancher/netes/server"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// isKubeconfig returns true for requests of GET /k8s/clusters/<id>/kubeconfig, or GET
+// /kubeconfig for requests routed to the cluster by host
+func isKubeconfig(req *http.Request, clusterID string, hostRouted bool) bool {
+	if req.Method != http.MethodGet || clusterID == "" {
+		return false
+	}
+	if hostRouted {
+		return req.URL.Path == "/kubeconfig"
+	}
+	return req.URL.Path == "/k8s/clusters/"+clusterID+"/kubeconfig"
+}
+
+// kubeconfig responds with a kubeconfig for the cluster using the credential of the caller. With
+// ?all=true it has a context for every cluster the caller can access, the requested cluster
+// being the current context.
+func (r *Router) kubeconfig(rw http.ResponseWriter, req *http.Request, c *client.Cluster, hostRouted bool) {
+	authInfo := kubeconfigAuthInfo(req)
+	if authInfo == nil {
+		response(rw, req, http.StatusBadRequest, "A kubeconfig can only be generated for callers using a token or API key")
+		return
+	}
+
+	ca, err := r.caData()
+	if err != nil {
+		response(rw, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	clusters := []*client.Cluster{c}
+	if req.URL.Query().Get("all") == "true" {
+		others, err := r.accessibleClusters(req, c.Id)
+		if err != nil {
+			response(rw, req, cluster.ErrorCode(err), err.Error())
+			return
+		}
+		clusters = append(clusters, others...)
+	}
+
+	config := clientcmdapi.NewConfig()
+	config.AuthInfos["netes"] = authInfo
+	config.CurrentContext = c.Id
+	for _, c := range clusters {
+		serverURL := r.serverURL(req, c.Id, hostRouted)
+		if serverURL == "" {
+			continue
+		}
+
+		kubeCluster := clientcmdapi.NewCluster()
+		kubeCluster.Server = serverURL
+		kubeCluster.CertificateAuthorityData = ca
+		config.Clusters[c.Id] = kubeCluster
+
+		context := clientcmdapi.NewContext()
+		context.Cluster = c.Id
+		context.AuthInfo = "netes"
+		config.Contexts[c.Id] = context
+	}
+
+	content, err := clientcmd.Write(*config)
+	if err != nil {
+		response(rw, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/yaml")
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(content)
+}
+
+// accessibleClusters returns the clusters other than the given one the caller's credential
+// gives access to
+func (r *Router) accessibleClusters(req *http.Request, clusterID string) ([]*client.Cluster, error) {
+	all, err := r.clusterSource.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*client.Cluster
+	for _, c := range all {
+		if c.Id == clusterID || c.Removed != "" || !server.HasServer(c) {
+			continue
+		}
+
+		lookupReq, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			return nil, err
+		}
+		lookupReq.Header = cloneHeader(req.Header)
+		lookupReq.Header.Set("X-API-Cluster-Id", c.Id)
+
+		accessible, err := r.clusterSource.Lookup(lookupReq)
+		if cluster.IsClientError(err) || (err == nil && accessible == nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, accessible)
+	}
+
+	return result, nil
+}
+
+// serverURL returns the URL of the cluster as reachable by the caller, or an empty string if the
+// cluster can not be reached with the host names netes is configured with
+func (r *Router) serverURL(req *http.Request, clusterID string, hostRouted bool) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+
+	if !hostRouted {
+		return scheme + "://" + req.Host + "/k8s/clusters/" + clusterID
+	}
+
+	if r.hostClusterID(req) == clusterID {
+		return scheme + "://" + req.Host
+	}
+
+	port := ""
+	if _, p, err := net.SplitHostPort(req.Host); err == nil {
+		port = ":" + p
+	}
+
+	if r.clusterDomain != "" {
+		return scheme + "://" + strings.ToLower(clusterID) + "." + r.clusterDomain + port
+	}
+
+	for host, id := range r.clusterHosts {
+		if id == clusterID {
+			return scheme + "://" + host + port
+		}
+	}
+
+	return ""
+}
+
+// caData returns the CA bundle clients should trust when TLS is enabled
+func (r *Router) caData() ([]byte, error) {
+	if r.caFile == "" {
+		return nil, nil
+	}
+	return ioutil.ReadFile(r.caFile)
+}
+
+// kubeconfigAuthInfo returns the credential the caller authenticated with, which netes will
+// accept again for requests made with the kubeconfig
+func kubeconfigAuthInfo(req *http.Request) *clientcmdapi.AuthInfo {
+	authInfo := clientcmdapi.NewAuthInfo()
+
+	if username, password, ok := req.BasicAuth(); ok {
+		authInfo.Username = username
+		authInfo.Password = password
+		return authInfo
+	}
+
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		authInfo.Token = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		return authInfo
+	}
+
+	if cookie, err := req.Cookie("token"); err == nil && cookie.Value != "" {
+		authInfo.Token = cookie.Value
+		return authInfo
+	}
+
+	return nil
+}
+
+func cloneHeader(header http.Header) http.Header {
+	result := http.Header{}
+	for k, v := range header {
+		result[k] = append([]string(nil), v...)
+	}
+	return result
+}
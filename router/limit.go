@@ -0,0 +1,193 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/netes/cluster"
+	"github.com/rancher/netes/server"
+	"github.com/rancher/netes/status"
+	"github.com/rancher/netes/types"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+const (
+	mutating    = "mutating"
+	readOnly    = "read-only"
+	longRunning = "long-running"
+
+	bucketIdleTimeout = 10 * time.Minute
+	limitRetryAfter   = 1
+)
+
+// limiter keeps separate budgets per cluster, and per user of a cluster, so that a single
+// tenant can not starve the other clusters served by this process.
+type limiter struct {
+	sync.Mutex
+	config  *types.GlobalConfig
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+type bucket struct {
+	rate     flowcontrol.RateLimiter
+	inflight chan struct{}
+	lastUsed time.Time
+}
+
+func newLimiter(config *types.GlobalConfig) *limiter {
+	return &limiter{
+		config:  config,
+		buckets: map[string]*bucket{},
+		pruned:  time.Now(),
+	}
+}
+
+// limit returns false, after answering with 429, if the request exceeds the budget of its
+// cluster or user. Otherwise the returned func must be called once the request is complete.
+func (l *limiter) limit(rw http.ResponseWriter, req *http.Request, clusterID, user string) (func(), bool) {
+	limits := l.config.LimitsFor(clusterID)
+	class := requestClass(clusterID, req)
+
+	var taken []*bucket
+	release := func() {
+		for _, b := range taken {
+			b.release()
+		}
+	}
+
+	checks := []struct {
+		key   string
+		limit types.Limit
+		who   string
+	}{
+		{"user/" + clusterID + "/" + user + "/" + class, classLimit(limits.User, class), "user " + user},
+		{"cluster/" + clusterID + "/" + class, classLimit(limits.Cluster, class), "cluster " + clusterID},
+	}
+
+	for _, check := range checks {
+		b := l.bucket(check.key, check.limit)
+		if b == nil {
+			continue
+		}
+		if !b.acquire() {
+			release()
+			message := fmt.Sprintf("Too many %s requests for %s, please try again later", class, check.who)
+			status.Write(rw, status.TooManyRequests(message, limitRetryAfter))
+			return nil, false
+		}
+		taken = append(taken, b)
+	}
+
+	return release, true
+}
+
+func (l *limiter) bucket(key string, limit types.Limit) *bucket {
+	if limit.QPS <= 0 && limit.MaxInFlight <= 0 {
+		return nil
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.pruned) > bucketIdleTimeout {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = newBucket(limit)
+		l.buckets[key] = b
+	}
+	b.lastUsed = now
+	return b
+}
+
+// prune forgets buckets which have not been used for a while and have no requests in flight
+func (l *limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.lastUsed) > bucketIdleTimeout && len(b.inflight) == 0 {
+			delete(l.buckets, key)
+		}
+	}
+	l.pruned = now
+}
+
+func newBucket(limit types.Limit) *bucket {
+	b := &bucket{}
+	if limit.QPS > 0 {
+		burst := limit.Burst
+		if burst <= 0 {
+			burst = 1
+		}
+		b.rate = flowcontrol.NewTokenBucketRateLimiter(limit.QPS, burst)
+	}
+	if limit.MaxInFlight > 0 {
+		b.inflight = make(chan struct{}, limit.MaxInFlight)
+	}
+	return b
+}
+
+func (b *bucket) acquire() bool {
+	if b.inflight != nil {
+		select {
+		case b.inflight <- struct{}{}:
+		default:
+			return false
+		}
+	}
+
+	if b.rate != nil && !b.rate.TryAccept() {
+		b.release()
+		return false
+	}
+
+	return true
+}
+
+func (b *bucket) release() {
+	if b.inflight != nil {
+		<-b.inflight
+	}
+}
+
+func requestClass(clusterID string, req *http.Request) string {
+	if server.IsLongRunning(req, server.RequestInfo(clusterID, req)) {
+		return longRunning
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return readOnly
+	}
+	return mutating
+}
+
+func classLimit(limits types.RequestLimits, class string) types.Limit {
+	switch class {
+	case longRunning:
+		return limits.LongRunning
+	case readOnly:
+		return limits.ReadOnly
+	}
+	return limits.Mutating
+}
+
+// limitUser returns the name the per user limits of the caller are kept under
+func limitUser(req *http.Request, identity *client.ClusterIdentity) string {
+	if identity != nil && identity.UserId != "" {
+		return identity.UserId
+	}
+	if certUser := clientCertUser(req); certUser != nil {
+		return "cert:" + certUser.GetName()
+	}
+	// service account and node tokens are only known to the cluster
+	if req.Header.Get("Authorization") != "" {
+		return "credential:" + cluster.CredentialKey("", req)
+	}
+	return "anonymous"
+}
@@ -0,0 +1,197 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rancher/netes/types"
+)
+
+func TestBucket(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    types.Limit
+		acquired int
+		// refill is how long to wait for another request to be accepted, zero if none is
+		refill time.Duration
+	}{
+		{
+			name:     "qps without burst",
+			limit:    types.Limit{QPS: 10},
+			acquired: 1,
+			refill:   150 * time.Millisecond,
+		},
+		{
+			name:     "qps with burst",
+			limit:    types.Limit{QPS: 10, Burst: 3},
+			acquired: 3,
+			refill:   150 * time.Millisecond,
+		},
+		{
+			name:     "max in flight",
+			limit:    types.Limit{MaxInFlight: 2},
+			acquired: 2,
+		},
+		{
+			name:     "qps and max in flight",
+			limit:    types.Limit{QPS: 10, Burst: 5, MaxInFlight: 2},
+			acquired: 2,
+		},
+	}
+
+	for _, test := range tests {
+		b := newBucket(test.limit)
+		for i := 0; i < test.acquired; i++ {
+			if !b.acquire() {
+				t.Errorf("%s: request %d refused", test.name, i)
+			}
+		}
+		if b.acquire() {
+			t.Errorf("%s: request %d accepted, expected it to exceed the limit", test.name, test.acquired)
+		}
+
+		if test.refill > 0 {
+			time.Sleep(test.refill)
+			if !b.acquire() {
+				t.Errorf("%s: request refused after waiting %v for a token", test.name, test.refill)
+			}
+		}
+	}
+}
+
+func TestBucketRelease(t *testing.T) {
+	b := newBucket(types.Limit{MaxInFlight: 1})
+	if !b.acquire() {
+		t.Fatal("expected the first request to be accepted")
+	}
+	if b.acquire() {
+		t.Fatal("expected a second request in flight to be refused")
+	}
+
+	b.release()
+	if !b.acquire() {
+		t.Fatal("expected a request to be accepted once the first one completed")
+	}
+}
+
+func TestLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *types.GlobalConfig
+		user     string
+		accepted bool
+	}{
+		{
+			name:     "unlimited",
+			config:   &types.GlobalConfig{},
+			accepted: true,
+		},
+		{
+			name: "user limit",
+			config: &types.GlobalConfig{
+				Limits: types.LimitConfig{
+					User: types.RequestLimits{ReadOnly: types.Limit{MaxInFlight: 1}},
+				},
+			},
+			user:     "u1",
+			accepted: false,
+		},
+		{
+			name: "user limit of another user",
+			config: &types.GlobalConfig{
+				Limits: types.LimitConfig{
+					User: types.RequestLimits{ReadOnly: types.Limit{MaxInFlight: 1}},
+				},
+			},
+			user:     "u2",
+			accepted: true,
+		},
+		{
+			name: "cluster limit",
+			config: &types.GlobalConfig{
+				Limits: types.LimitConfig{
+					Cluster: types.RequestLimits{ReadOnly: types.Limit{MaxInFlight: 1}},
+				},
+			},
+			user:     "u2",
+			accepted: false,
+		},
+		{
+			name: "limit of another cluster",
+			config: &types.GlobalConfig{
+				ClusterLimits: map[string]types.LimitConfig{
+					"c2": {Cluster: types.RequestLimits{ReadOnly: types.Limit{MaxInFlight: 1}}},
+				},
+			},
+			user:     "u2",
+			accepted: true,
+		},
+		{
+			name: "limit of another class",
+			config: &types.GlobalConfig{
+				Limits: types.LimitConfig{
+					Cluster: types.RequestLimits{Mutating: types.Limit{MaxInFlight: 1}},
+				},
+			},
+			user:     "u2",
+			accepted: true,
+		},
+	}
+
+	for _, test := range tests {
+		l := newLimiter(test.config)
+		req := httptest.NewRequest(http.MethodGet, "/k8s/clusters/c1/api/v1/namespaces/default/pods", nil)
+
+		if _, ok := l.limit(httptest.NewRecorder(), req, "c1", "u1"); !ok {
+			t.Errorf("%s: first request refused", test.name)
+			continue
+		}
+
+		rw := httptest.NewRecorder()
+		release, ok := l.limit(rw, req, "c1", test.user)
+		if ok != test.accepted {
+			t.Errorf("%s: accepted %v, expected %v", test.name, ok, test.accepted)
+		}
+		if ok {
+			release()
+		} else if rw.Code != http.StatusTooManyRequests {
+			t.Errorf("%s: responded %d, expected %d", test.name, rw.Code, http.StatusTooManyRequests)
+		}
+	}
+}
+
+func TestRequestClass(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		expected string
+		limit    types.Limit
+	}{
+		{http.MethodGet, "/k8s/clusters/c1/api/v1/namespaces/default/pods", readOnly, types.Limit{QPS: 2}},
+		{http.MethodHead, "/k8s/clusters/c1/api/v1/namespaces/default/pods", readOnly, types.Limit{QPS: 2}},
+		{http.MethodPost, "/k8s/clusters/c1/api/v1/namespaces/default/pods", mutating, types.Limit{QPS: 1}},
+		{http.MethodDelete, "/k8s/clusters/c1/api/v1/namespaces/default/pods/p1", mutating, types.Limit{QPS: 1}},
+		{http.MethodGet, "/k8s/clusters/c1/api/v1/namespaces/default/pods?watch=true", longRunning, types.Limit{QPS: 3}},
+		{http.MethodGet, "/k8s/clusters/c1/api/v1/watch/namespaces/default/pods", longRunning, types.Limit{QPS: 3}},
+		{http.MethodPost, "/k8s/clusters/c1/api/v1/namespaces/default/pods/p1/exec", longRunning, types.Limit{QPS: 3}},
+	}
+
+	limits := types.RequestLimits{
+		Mutating:    types.Limit{QPS: 1},
+		ReadOnly:    types.Limit{QPS: 2},
+		LongRunning: types.Limit{QPS: 3},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		class := requestClass("c1", req)
+		if class != test.expected {
+			t.Errorf("%s %s: class %s, expected %s", test.method, test.path, class, test.expected)
+		}
+		if limit := classLimit(limits, class); limit != test.limit {
+			t.Errorf("%s %s: limit %v, expected %v", test.method, test.path, limit, test.limit)
+		}
+	}
+}
@@ -3,46 +3,194 @@ package router
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/rancher/go-rancher/v3"
 	"github.com/rancher/netes/cluster"
 	"github.com/rancher/netes/server"
+	"github.com/rancher/netes/status"
 	"github.com/rancher/netes/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/cache"
 )
 
+const (
+	clusterCacheSize = 1000
+	clusterCacheTTL  = 30 * time.Second
+)
+
+// servers returns the server of a cluster, implemented by server.Factory
+type servers interface {
+	Get(c *client.Cluster) (server.Server, error)
+}
+
 type Router struct {
-	clusterLookup *cluster.Lookup
-	serverFactory *server.Factory
+	clusterSource cluster.ClusterSource
+	serverFactory servers
+	membership    replicas
+	nonces        *nonces
+	peerTransport *http.Transport
+	clusterDomain string
+	clusterHosts  map[string]string
+	limiter       *limiter
+	caFile        string
+	clusters      *cache.LRUExpireCache
 }
 
-func New(config *types.GlobalConfig) *Router {
-	return &Router{
-		clusterLookup: config.Lookup,
-		serverFactory: server.NewFactory(config),
+func New(config *types.GlobalConfig, serverFactory *server.Factory) (*Router, error) {
+	caFile := ""
+	if config.TLS != nil {
+		caFile = types.FirstNotEmpty(config.TLS.CAFile, config.TLS.CertFile)
+	}
+
+	peerTransport, err := newPeerTransport(config)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Router{
+		clusterSource: config.ClusterSource,
+		serverFactory: serverFactory,
+		nonces:        newNonces(),
+		peerTransport: peerTransport,
+		clusterDomain: strings.ToLower(config.ClusterDomain),
+		clusterHosts:  lowerKeys(config.ClusterHosts),
+		limiter:       newLimiter(config),
+		caFile:        caFile,
+		clusters:      cache.NewLRUExpireCache(clusterCacheSize),
 	}
+	if config.Membership != nil {
+		r.membership = config.Membership
+	}
+	return r, nil
 }
 
 func (r *Router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	c, handler, err := r.serverFactory.Get(req)
+	req, forwarded := r.verifyForwarded(req)
+
+	hostRouted := false
+	if req.Header.Get("X-API-Cluster-Id") == "" {
+		if clusterID := r.hostClusterID(req); clusterID != "" {
+			req = req.WithContext(cluster.StoreClusterID(req.Context(), clusterID))
+			hostRouted = true
+		}
+	}
+
+	// kubeconfigs are generated by any replica, they only depend on the cluster source
+	kubeconfig := isKubeconfig(req, cluster.GetClusterID(req), hostRouted)
+	if !kubeconfig && r.forward(rw, req, cluster.GetClusterID(req), forwarded) {
+		return
+	}
+
+	c, identity, err := r.lookup(req, kubeconfig)
 	if err != nil {
-		response(rw, http.StatusInternalServerError, err.Error())
+		response(rw, req, cluster.ErrorCode(err), err.Error())
 		return
 	}
 
 	if c == nil {
-		response(rw, http.StatusNotFound, "No c available")
+		response(rw, req, http.StatusNotFound, "Cluster not found")
+		return
+	}
+
+	if kubeconfig {
+		r.kubeconfig(rw, req, c, hostRouted)
+		return
+	}
+
+	release, ok := r.limiter.limit(rw, req, c.Id, limitUser(req, identity))
+	if !ok {
+		return
+	}
+	defer release()
+
+	server, err := r.serverFactory.Get(c)
+	if statusErr, ok := err.(*apierrors.StatusError); ok {
+		status.Write(rw, statusErr)
+		return
+	}
+	if err != nil {
+		response(rw, req, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if server == nil {
+		response(rw, req, http.StatusNotFound, "No server available for cluster "+c.Id)
 		return
 	}
 
-	ctx := cluster.StoreCluster(req.Context(), c)
-	handler.ServeHTTP(rw, req.WithContext(ctx))
+	ctx := cluster.StoreCluster(req.Context(), server.Cluster())
+	if identity != nil {
+		ctx = cluster.StoreCallerIdentity(ctx, identity)
+	}
+	server.Handler().ServeHTTP(rw, req.WithContext(ctx))
 }
 
-func response(rw http.ResponseWriter, code int, message string) {
-	rw.WriteHeader(code)
+// lookup resolves the cluster with the caller's credentials. Callers the cluster source does not
+// know, such as those presenting a verified client certificate, a service account token or a
+// node token, are passed to embedded clusters without an identity and the authenticators of the
+// cluster decide whether they are allowed. Remote clusters are only proxied for them if they
+// present a client certificate, as the proxy authenticates with the credential of netes, and
+// kubeconfigs are only generated for callers known to the cluster source.
+func (r *Router) lookup(req *http.Request, kubeconfig bool) (*client.Cluster, *client.ClusterIdentity, error) {
+	c, err := r.clusterSource.Lookup(req)
+	if err == nil && c != nil {
+		return c, &c.Identity, nil
+	}
+
+	if kubeconfig || cluster.ErrorCode(err) != http.StatusUnauthorized {
+		return nil, nil, err
+	}
+
+	certUser := clientCertUser(req)
+	byID, idErr := r.lookupByID(cluster.GetClusterID(req))
+	if idErr != nil {
+		return nil, nil, idErr
+	}
+	if certUser != nil {
+		return byID, nil, nil
+	}
+	if byID == nil || !byID.Embedded {
+		return nil, nil, err
+	}
+	return byID, nil, nil
+}
+
+// lookupByID caches the clusters resolved without a caller, as the service accounts and nodes of
+// a cluster would otherwise fetch it from the cluster source on every request
+func (r *Router) lookupByID(clusterID string) (*client.Cluster, error) {
+	if c, ok := r.clusters.Get(clusterID); ok {
+		return c.(*client.Cluster), nil
+	}
+
+	c, err := r.clusterSource.LookupByID(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	r.clusters.Add(clusterID, c, clusterCacheTTL)
+	return c, nil
+}
+
+func response(rw http.ResponseWriter, req *http.Request, code int, message string) {
+	if isKubernetesClient(req) {
+		status.Write(rw, status.New(code, message))
+		return
+	}
+
 	rw.Header().Set("content-type", "application/json")
+	rw.WriteHeader(code)
 	json.NewEncoder(rw).Encode(&client.Error{
 		Status:  int64(code),
 		Message: message,
 	})
 }
+
+// isKubernetesClient returns true for kubectl and other clients built on client-go, which
+// expect errors as Kubernetes Status objects
+func isKubernetesClient(req *http.Request) bool {
+	userAgent := req.Header.Get("User-Agent")
+	return strings.HasPrefix(userAgent, "kubectl/") ||
+		strings.Contains(userAgent, " kubernetes/") ||
+		strings.Contains(req.Header.Get("Accept"), "application/vnd.kubernetes.protobuf")
+}
@@ -0,0 +1,178 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/netes/cluster"
+	"github.com/rancher/netes/server"
+	"github.com/rancher/netes/types"
+	"k8s.io/apimachinery/pkg/util/cache"
+)
+
+// fakeSource knows a single Rancher API key, giving it access to every cluster
+type fakeSource struct {
+	clusters map[string]*client.Cluster
+}
+
+func (f *fakeSource) Lookup(req *http.Request) (*client.Cluster, error) {
+	c := f.clusters[cluster.GetClusterID(req)]
+	if req.Header.Get("Authorization") != "Bearer rancher" {
+		return nil, cluster.NewLookupError(http.StatusUnauthorized, "Invalid or missing credentials")
+	}
+	if c == nil {
+		return nil, cluster.NewLookupError(http.StatusNotFound, "Cluster not found")
+	}
+
+	result := *c
+	result.Identity = client.ClusterIdentity{
+		Username: "rancher-user",
+		UserId:   "1a1",
+	}
+	return &result, nil
+}
+
+func (f *fakeSource) LookupByID(clusterID string) (*client.Cluster, error) {
+	return f.clusters[clusterID], nil
+}
+
+func (f *fakeSource) List() ([]*client.Cluster, error) {
+	var clusters []*client.Cluster
+	for _, c := range f.clusters {
+		clusters = append(clusters, c)
+	}
+	return clusters, nil
+}
+
+func (f *fakeSource) HealthCheck() error {
+	return nil
+}
+
+// fakeServers answers every request with the identity resolved by the router, as the
+// authenticators of the cluster would see it
+type fakeServers struct{}
+
+func (f *fakeServers) Get(c *client.Cluster) (server.Server, error) {
+	return &fakeServer{cluster: c}, nil
+}
+
+type fakeServer struct {
+	cluster *client.Cluster
+}
+
+func (f *fakeServer) Close() {
+}
+
+func (f *fakeServer) Cluster() *client.Cluster {
+	return f.cluster
+}
+
+func (f *fakeServer) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Authorization", req.Header.Get("Authorization"))
+		if identity := cluster.GetCallerIdentity(req.Context()); identity != nil {
+			rw.Header().Set("X-Username", identity.Username)
+		}
+		rw.WriteHeader(http.StatusOK)
+	})
+}
+
+func newTestRouter() *Router {
+	embedded := &client.Cluster{Embedded: true}
+	embedded.Id = "1c1"
+	remote := &client.Cluster{K8sClientConfig: &client.K8sClientConfig{Address: "https://remote"}}
+	remote.Id = "1c2"
+
+	return &Router{
+		clusterSource: &fakeSource{
+			clusters: map[string]*client.Cluster{
+				embedded.Id: embedded,
+				remote.Id:   remote,
+			},
+		},
+		serverFactory: &fakeServers{},
+		limiter:       newLimiter(&types.GlobalConfig{}),
+		nonces:        newNonces(),
+		clusters:      cache.NewLRUExpireCache(clusterCacheSize),
+	}
+}
+
+func TestRouterCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		auth     string
+		code     int
+		username string
+	}{
+		{
+			name:     "rancher key",
+			path:     "/k8s/clusters/1c1/api/v1/pods",
+			auth:     "Bearer rancher",
+			code:     http.StatusOK,
+			username: "rancher-user",
+		},
+		{
+			name: "service account token",
+			path: "/k8s/clusters/1c1/api/v1/pods",
+			auth: "Bearer eyJhbGciOiJSUzI1NiJ9.e30.c2ln",
+			code: http.StatusOK,
+		},
+		{
+			name: "node token",
+			path: "/k8s/clusters/1c1/api/v1/nodes",
+			auth: "Bearer node-0123456789abcdef",
+			code: http.StatusOK,
+		},
+		{
+			name: "no credentials",
+			path: "/k8s/clusters/1c1/api/v1/pods",
+			code: http.StatusOK,
+		},
+		{
+			name: "unknown token for remote cluster",
+			path: "/k8s/clusters/1c2/api/v1/pods",
+			auth: "Bearer eyJhbGciOiJSUzI1NiJ9.e30.c2ln",
+			code: http.StatusUnauthorized,
+		},
+		{
+			name: "unknown token for missing cluster",
+			path: "/k8s/clusters/1c3/api/v1/pods",
+			auth: "Bearer eyJhbGciOiJSUzI1NiJ9.e30.c2ln",
+			code: http.StatusUnauthorized,
+		},
+		{
+			name: "kubeconfig for unknown token",
+			path: "/k8s/clusters/1c1/kubeconfig",
+			auth: "Bearer eyJhbGciOiJSUzI1NiJ9.e30.c2ln",
+			code: http.StatusUnauthorized,
+		},
+	}
+
+	r := newTestRouter()
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.auth != "" {
+			req.Header.Set("Authorization", test.auth)
+		}
+
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, req)
+
+		if rw.Code != test.code {
+			t.Errorf("%s: responded %d, expected %d", test.name, rw.Code, test.code)
+			continue
+		}
+		if rw.Code != http.StatusOK {
+			continue
+		}
+		if auth := rw.Header().Get("X-Authorization"); auth != test.auth {
+			t.Errorf("%s: cluster received credentials %q, expected %q", test.name, auth, test.auth)
+		}
+		if username := rw.Header().Get("X-Username"); username != test.username {
+			t.Errorf("%s: cluster received identity %q, expected %q", test.name, username, test.username)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/netes/cluster"
+	"github.com/rancher/netes/types"
+)
+
+// ServerInfo describes the server of a cluster for the admin API
+type ServerInfo struct {
+	ClusterID       string                  `json:"clusterId"`
+	Name            string                  `json:"name,omitempty"`
+	Embedded        bool                    `json:"embedded"`
+	State           State                   `json:"state"`
+	Error           string                  `json:"error,omitempty"`
+	Created         time.Time               `json:"created"`
+	Uptime          string                  `json:"uptime"`
+	LastUsed        time.Time               `json:"lastUsed"`
+	Requests        int64                   `json:"requests"`
+	InFlight        int64                   `json:"inFlight"`
+	Watches         int64                   `json:"watches"`
+	K8sServerConfig *client.K8sServerConfig `json:"k8sServerConfig,omitempty"`
+}
+
+// List returns the servers of all clusters the factory knows about
+func (s *Factory) List() []ServerInfo {
+	var result []ServerInfo
+	s.servers.Range(func(key, value interface{}) bool {
+		result = append(result, s.info(value.(*trackedServer)))
+		return true
+	})
+	return result
+}
+
+// Info returns the server of the cluster, or nil if there is none
+func (s *Factory) Info(clusterID string) *ServerInfo {
+	server := s.lookupServer(clusterID)
+	if server == nil {
+		return nil
+	}
+
+	info := s.info(server)
+	return &info
+}
+
+// Rebuild replaces the server of the cluster with a new one built from the current
+// configuration of the cluster, draining the old one
+func (s *Factory) Rebuild(clusterID string) error {
+	server := s.lookupServer(clusterID)
+	if server == nil {
+		return fmt.Errorf("no server for cluster %s", clusterID)
+	}
+
+	if s.membership != nil && !s.membership.Owns(clusterID) {
+		s.remove(clusterID, server, "cluster moved to another replica")
+		return cluster.NewLookupError(http.StatusMisdirectedRequest, "Cluster %s is served by another replica", clusterID)
+	}
+
+	c, err := s.clusterSource.LookupByID(clusterID)
+	if err != nil {
+		return err
+	}
+	if c == nil || c.Removed != "" || !HasServer(c) {
+		s.remove(clusterID, server, "cluster was removed")
+		return fmt.Errorf("no server available for cluster %s", clusterID)
+	}
+
+	s.remove(clusterID, server, "rebuild requested")
+	return s.start(s.newTrackedServer(c))
+}
+
+// Drain stops routing requests to the server of the cluster and closes it once its requests
+// have finished. A new server is built on the next request.
+func (s *Factory) Drain(clusterID string) error {
+	server := s.lookupServer(clusterID)
+	if server == nil {
+		return fmt.Errorf("no server for cluster %s", clusterID)
+	}
+
+	s.remove(clusterID, server, "drain requested")
+	return nil
+}
+
+// Evict closes the server of the cluster immediately, without waiting for its requests
+func (s *Factory) Evict(clusterID string) error {
+	server := s.lookupServer(clusterID)
+	if server == nil || !s.unregister(clusterID, server) {
+		return fmt.Errorf("no server for cluster %s", clusterID)
+	}
+
+	logrus.Infof("Evicting server for cluster %s with %d requests in flight", clusterID, server.InFlight())
+	server.Close()
+	return nil
+}
+
+func (s *Factory) info(server *trackedServer) ServerInfo {
+	c := server.Cluster()
+	state, err := server.State()
+
+	server.Lock()
+	created := server.created
+	server.Unlock()
+
+	info := ServerInfo{
+		ClusterID: c.Id,
+		Name:      c.Name,
+		Embedded:  c.Embedded,
+		State:     state,
+		Created:   created,
+		LastUsed:  server.LastUsed(),
+		Requests:  server.Requests(),
+		InFlight:  server.InFlight(),
+		Watches:   server.Watches(),
+	}
+	if err != nil {
+		info.Error = err.Error()
+	}
+	if state == StateReady {
+		info.Uptime = time.Since(created).String()
+	}
+	if c.Embedded {
+		info.K8sServerConfig = &client.K8sServerConfig{
+			AdmissionControllers: types.FirstNotLenZero(c.K8sServerConfig.AdmissionControllers, s.config.AdmissionControllers),
+			ServiceNetCidr:       types.FirstNotEmpty(c.K8sServerConfig.ServiceNetCidr, s.config.ServiceNetCidr),
+		}
+	}
+
+	return info
+}
@@ -2,10 +2,12 @@ package embedded
 
 import (
 	"context"
+	"crypto/rsa"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
-	"os"
+	"net/http/httptest"
 	"strings"
 	"time"
 
@@ -16,6 +18,7 @@ import (
 	"github.com/rancher/netes/authorization"
 	"github.com/rancher/netes/clients"
 	"github.com/rancher/netes/cluster"
+	"github.com/rancher/netes/controllermanager"
 	"github.com/rancher/netes/proxy"
 	"github.com/rancher/netes/server/admission"
 	"github.com/rancher/netes/store"
@@ -27,12 +30,19 @@ import (
 	"k8s.io/apiserver/pkg/server/storage"
 	"k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/generated/openapi"
+	"k8s.io/kubernetes/pkg/kubeapiserver/authorizer/modes"
 	kubeletclient "k8s.io/kubernetes/pkg/kubelet/client"
 	"k8s.io/kubernetes/pkg/master"
 	"k8s.io/kubernetes/pkg/master/ports"
 	"k8s.io/kubernetes/pkg/version"
 )
 
+// LongRunningRequestCheck classifies the requests the apiserver treats as long running
+var LongRunningRequestCheck = filters.BasicLongRunningRequestCheck(
+	sets.NewString("watch", "proxy"),
+	sets.NewString("attach", "exec", "proxy", "log", "portforward"),
+)
+
 type embeddedServer struct {
 	master  *master.Master
 	cluster *client.Cluster
@@ -55,7 +65,27 @@ func (e *embeddedServer) Cluster() *client.Cluster {
 	return e.cluster
 }
 
-func New(config *types.GlobalConfig, cluster *client.Cluster, lookup *cluster.Lookup) (*embeddedServer, error) {
+// Healthz runs the healthz checks of the apiserver as netes itself
+func (e *embeddedServer) Healthz() error {
+	req, err := http.NewRequest("GET", "/healthz", nil)
+	if err != nil {
+		return err
+	}
+
+	ctx := cluster.StoreCluster(req.Context(), e.cluster)
+	ctx = cluster.StoreIdentity(ctx, &client.ClusterIdentity{
+		Username: "system:netes",
+	})
+
+	rw := httptest.NewRecorder()
+	e.master.GenericAPIServer.Handler.ServeHTTP(rw, req.WithContext(ctx))
+	if rw.Code != http.StatusOK {
+		return fmt.Errorf("healthz responded %d: %s", rw.Code, strings.TrimSpace(rw.Body.String()))
+	}
+	return nil
+}
+
+func New(config *types.GlobalConfig, cluster *client.Cluster) (*embeddedServer, error) {
 	storageFactory, err := store.StorageFactory(
 		fmt.Sprintf("/k8s/cluster/%s", cluster.Uuid),
 		config)
@@ -68,7 +98,22 @@ func New(config *types.GlobalConfig, cluster *client.Cluster, lookup *cluster.Lo
 		return nil, err
 	}
 
-	genericApiServerConfig, err := genericConfig(config, cluster, lookup, storageFactory, clientsetset)
+	serviceAccountKey, err := authentication.ServiceAccountKey(context.Background(), config.Store, cluster.Uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	rootCA, err := rootCA(config)
+	if err != nil {
+		return nil, err
+	}
+
+	authorizationModes, err := authorization.Modes(config, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	genericApiServerConfig, err := genericConfig(config, cluster, storageFactory, clientsetset, serviceAccountKey)
 	if err != nil {
 		return nil, err
 	}
@@ -78,7 +123,7 @@ func New(config *types.GlobalConfig, cluster *client.Cluster, lookup *cluster.Lo
 		return nil, errors.Wrap(err, "Invalid service net cidr")
 	}
 
-	dialer := proxy.NewDialer(cluster, os.Getenv("CATTLE_ACCESS_KEY"), os.Getenv("CATTLE_SECRET_KEY"))
+	dialer := proxy.NewDialer(cluster, config.CattleAccessKey, config.CattleSecretKey)
 
 	masterConfig := &master.Config{
 		GenericConfig: genericApiServerConfig,
@@ -123,10 +168,33 @@ func New(config *types.GlobalConfig, cluster *client.Cluster, lookup *cluster.Lo
 	}
 
 	kubeAPIServer, err := masterConfig.Complete().New(genericapiserver.EmptyDelegate, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	clientsetset.Serve(kubeAPIServer.GenericAPIServer.Handler)
+
 	kubeAPIServer.GenericAPIServer.AddPostStartHook("start-kube-apiserver-informers", func(context genericapiserver.PostStartHookContext) error {
 		clientsetset.Start(context.StopCh)
 		return nil
 	})
+	kubeAPIServer.GenericAPIServer.AddPostStartHook("start-service-account-controllers", func(context genericapiserver.PostStartHookContext) error {
+		controllermanager.StartServiceAccountControllers(clientsetset, serviceAccountKey, rootCA, context.StopCh)
+		return nil
+	})
+	if sets.NewString(authorizationModes...).Has(modes.ModeNode) {
+		kubeAPIServer.GenericAPIServer.AddPostStartHook("unbind-nodes-group", func(context genericapiserver.PostStartHookContext) error {
+			authorization.StartNodesGroupUnbinding(clientsetset.Client, context.StopCh)
+			return nil
+		})
+	}
+	if config.Members != nil {
+		kubeAPIServer.GenericAPIServer.AddPostStartHook("start-member-binding-controller", func(context genericapiserver.PostStartHookContext) error {
+			controllermanager.StartMemberBindingController(clientsetset, config.Members, cluster.Id, config.GroupPrefix,
+				config.MemberRoles, context.StopCh)
+			return nil
+		})
+	}
 	kubeAPIServer.GenericAPIServer.PrepareRun()
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -151,9 +219,17 @@ func serviceNet(config *types.GlobalConfig, cluster *client.Cluster) (net.IPNet,
 	return master.DefaultServiceIPRange(*cidrNet)
 }
 
-func genericConfig(config *types.GlobalConfig, cluster *client.Cluster, lookup *cluster.Lookup,
-	storageFactory storage.StorageFactory, clientsetset *clients.ClientSetSet) (*genericapiserver.Config, error) {
-	authz, err := authorization.New()
+// rootCA returns the CA bundle put in service account token secrets for pods to trust netes
+func rootCA(config *types.GlobalConfig) ([]byte, error) {
+	if config.TLS == nil {
+		return nil, nil
+	}
+	return ioutil.ReadFile(types.FirstNotEmpty(config.TLS.CAFile, config.TLS.CertFile))
+}
+
+func genericConfig(config *types.GlobalConfig, cluster *client.Cluster,
+	storageFactory storage.StorageFactory, clientsetset *clients.ClientSetSet, serviceAccountKey *rsa.PrivateKey) (*genericapiserver.Config, error) {
+	authz, err := authorization.New(config, cluster, clientsetset)
 	if err != nil {
 		return nil, err
 	}
@@ -177,15 +253,16 @@ func genericConfig(config *types.GlobalConfig, cluster *client.Cluster, lookup *
 		},
 	}
 	genericApiServerConfig.SwaggerConfig = genericapiserver.DefaultSwaggerConfig()
-	genericApiServerConfig.LongRunningFunc = filters.BasicLongRunningRequestCheck(
-		sets.NewString("watch", "proxy"),
-		sets.NewString("attach", "exec", "proxy", "log", "portforward"),
-	)
+	genericApiServerConfig.LongRunningFunc = LongRunningRequestCheck
 	genericApiServerConfig.LoopbackClientConfig = &clientsetset.LoopbackClientConfig
 	genericApiServerConfig.AdmissionControl = admissions
 	genericApiServerConfig.Authorizer = authz
 	genericApiServerConfig.RESTOptionsGetter = &store.RESTOptionsFactory{storageFactory}
-	genericApiServerConfig.Authenticator = authentication.New(lookup)
+	genericApiServerConfig.Authenticator, err = authentication.New(config, cluster,
+		authentication.NewServiceAccountAuthenticator(serviceAccountKey, clientsetset))
+	if err != nil {
+		return nil, err
+	}
 	genericApiServerConfig.Authorizer = authz
 	genericApiServerConfig.PublicAddress = net.ParseIP("169.254.169.250")
 	genericApiServerConfig.ReadWritePort = 9348
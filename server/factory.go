@@ -1,83 +1,140 @@
 package server
 
 import (
-	"net/http"
+	"sync"
 
 	"github.com/docker/docker/pkg/locker"
 	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/netes/authorization"
 	"github.com/rancher/netes/cluster"
+	"github.com/rancher/netes/membership"
 	"github.com/rancher/netes/server/embedded"
+	"github.com/rancher/netes/server/remote"
 	"github.com/rancher/netes/types"
 	"golang.org/x/sync/syncmap"
 )
 
 type Factory struct {
-	clusterLookup *cluster.Lookup
-	clusters      syncmap.Map
+	clusterSource cluster.ClusterSource
+	membership    *membership.Membership
 	config        *types.GlobalConfig
 	serverLock    *locker.Locker
+	removeLock    sync.Mutex
+	admitLock     sync.Mutex
 	servers       syncmap.Map
+	prewarmed     int32
 }
 
 func NewFactory(config *types.GlobalConfig) *Factory {
 	return &Factory{
 		serverLock:    locker.New(),
 		config:        config,
-		clusterLookup: config.Lookup,
+		clusterSource: config.ClusterSource,
+		membership:    config.Membership,
 	}
 }
 
-func (s *Factory) lookupCluster(clusterID string) (*client.Cluster, http.Handler) {
+func (s *Factory) lookupServer(clusterID string) *trackedServer {
 	server, ok := s.servers.Load(clusterID)
 	if ok {
-		if cluster, ok := s.clusters.Load(clusterID); ok {
-			return cluster.(*client.Cluster), server.(Server).Handler()
-		}
+		return server.(*trackedServer)
 	}
 
-	return nil, nil
+	return nil
 }
 
-func (s *Factory) Get(req *http.Request) (*client.Cluster, http.Handler, error) {
-	clusterID := cluster.GetClusterID(req)
-	cluster, handler := s.lookupCluster(clusterID)
-	if cluster != nil {
-		return cluster, handler, nil
+// Get returns the server for the given cluster, starting to build it in the background if
+// needed. The server answers with 503 until it is ready. The cluster passed in may carry the
+// identity of the caller, which is never retained by the server.
+func (s *Factory) Get(c *client.Cluster) (Server, error) {
+	server := s.lookupServer(c.Id)
+	if server == nil {
+		if !HasServer(c) {
+			return nil, nil
+		}
+		server = s.newTrackedServer(c)
 	}
 
-	s.serverLock.Lock("cluster." + clusterID)
-	defer s.serverLock.Unlock("cluster." + clusterID)
+	if err := s.start(server); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
 
-	cluster, handler = s.lookupCluster(clusterID)
-	if cluster != nil {
-		return cluster, handler, nil
+// start builds the server unless it is already built or being built. Embedded servers are
+// admitted one at a time so that together they never exceed the configured maximum.
+func (s *Factory) start(server *trackedServer) error {
+	if !server.Cluster().Embedded || s.config.MaxEmbeddedServers <= 0 {
+		server.start(s.newServer)
+		return nil
 	}
 
-	cluster, err := s.clusterLookup.Lookup(req)
-	if err != nil || cluster == nil {
-		return nil, nil, err
+	if !server.startable() {
+		return nil
 	}
 
-	if cluster.K8sServerConfig == nil {
-		cluster.K8sServerConfig = &client.K8sServerConfig{}
+	s.admitLock.Lock()
+	defer s.admitLock.Unlock()
+
+	if !server.startable() {
+		return nil
+	}
+	if err := s.makeRoom(server); err != nil {
+		return err
 	}
 
-	var server interface{}
-	server, err = s.newServer(cluster)
-	if err != nil || server == nil {
-		return nil, nil, err
+	server.start(s.newServer)
+	return nil
+}
+
+func (s *Factory) newTrackedServer(c *client.Cluster) *trackedServer {
+	s.serverLock.Lock("cluster." + c.Id)
+	defer s.serverLock.Unlock("cluster." + c.Id)
+
+	server := s.lookupServer(c.Id)
+	if server != nil {
+		return server
 	}
 
-	server, _ = s.servers.LoadOrStore(cluster.Id, server)
-	s.clusters.LoadOrStore(cluster.Id, cluster)
+	server = newTrackedServer(clusterConfig(c))
+	server.authorization = s.authorization(c)
+	s.servers.Store(c.Id, server)
+	return server
+}
 
-	return cluster, server.(Server).Handler(), nil
+// HasServer returns true if netes can serve the API of the cluster
+func HasServer(c *client.Cluster) bool {
+	return c.Embedded || (c.K8sClientConfig != nil && c.K8sClientConfig.Address != "")
 }
 
 func (s *Factory) newServer(c *client.Cluster) (Server, error) {
 	if c.Embedded {
-		return embedded.New(s.config, c, s.config.Lookup)
+		return embedded.New(s.config, c)
+	}
+
+	if c.K8sClientConfig != nil && c.K8sClientConfig.Address != "" {
+		return remote.New(s.config, c)
 	}
 
 	return nil, nil
 }
+
+// authorization returns the effective authorization of the cluster, which is configured for netes
+// rather than in the cluster
+func (s *Factory) authorization(c *client.Cluster) types.AuthorizationConfig {
+	authorizationConfig, _ := s.config.AuthorizationFor(c.Id)
+	authorizationConfig.Modes, _ = authorization.Modes(s.config, c)
+	return authorizationConfig
+}
+
+// clusterConfig returns a copy of the cluster without the caller specific identity so that it
+// can be shared by all requests to the cluster.
+func clusterConfig(c *client.Cluster) *client.Cluster {
+	config := *c
+	config.Identity = client.ClusterIdentity{}
+	if config.K8sServerConfig == nil {
+		config.K8sServerConfig = &client.K8sServerConfig{}
+	}
+
+	return &config
+}
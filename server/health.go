@@ -0,0 +1,19 @@
+package server
+
+// Healthz runs the health checks of every ready server that supports them
+func (s *Factory) Healthz() map[string]error {
+	result := map[string]error{}
+	s.servers.Range(func(key, value interface{}) bool {
+		server := value.(*trackedServer)
+
+		server.Lock()
+		checker, ok := server.server.(HealthChecker)
+		server.Unlock()
+
+		if ok {
+			result[key.(string)] = checker.Healthz()
+		}
+		return true
+	})
+	return result
+}
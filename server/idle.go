@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/rancher/netes/status"
+)
+
+const (
+	idleCheckInterval = time.Minute
+	noRoomRetryAfter  = 10
+)
+
+// hibernateIdle closes embedded servers that have not served a request, and have no open
+// watches, for longer than the configured idle timeout. They are rebuilt on the next request.
+func (s *Factory) hibernateIdle() {
+	if s.config.IdleTimeout <= 0 {
+		return
+	}
+
+	for _, server := range s.embeddedServers() {
+		idle := time.Since(server.LastUsed())
+		if server.InFlight() == 0 && idle > s.config.IdleTimeout {
+			s.remove(server.Cluster().Id, server, "idle for "+idle.String())
+		}
+	}
+}
+
+// makeRoom evicts the least recently used idle embedded servers until the given one can be
+// started without exceeding the configured maximum. Servers with requests in flight are never
+// evicted, the new server is refused with 503 instead if there is not enough room without them.
+// It must be called with the admit lock held.
+func (s *Factory) makeRoom(starting *trackedServer) error {
+	var running, idle []*trackedServer
+	for _, server := range s.embeddedServers() {
+		if server == starting || !server.running() {
+			continue
+		}
+		running = append(running, server)
+		if server.InFlight() == 0 {
+			idle = append(idle, server)
+		}
+	}
+
+	evict := len(running) - s.config.MaxEmbeddedServers + 1
+	if evict <= 0 {
+		return nil
+	}
+	if evict > len(idle) {
+		return status.ServiceUnavailable(fmt.Sprintf("Reached limit of %d embedded servers, all of them are busy",
+			s.config.MaxEmbeddedServers), noRoomRetryAfter)
+	}
+
+	sort.Slice(idle, func(i, j int) bool {
+		return idle[i].LastUsed().Before(idle[j].LastUsed())
+	})
+
+	logrus.Infof("Reached limit of %d embedded servers, evicting %d to start cluster %s",
+		s.config.MaxEmbeddedServers, evict, starting.Cluster().Id)
+	for _, server := range idle[:evict] {
+		s.remove(server.Cluster().Id, server, "evicted as least recently used")
+	}
+	return nil
+}
+
+func (s *Factory) embeddedServers() []*trackedServer {
+	var servers []*trackedServer
+	s.servers.Range(func(key, value interface{}) bool {
+		server := value.(*trackedServer)
+		if server.Cluster().Embedded {
+			servers = append(servers, server)
+		}
+		return true
+	})
+	return servers
+}
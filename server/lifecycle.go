@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/v3"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	syncInterval = 30 * time.Second
+	drainTimeout = time.Minute
+)
+
+var inactiveStates = sets.NewString("inactive", "deactivating", "removing", "removed", "purging", "purged")
+
+// Watch periodically checks every running server against Cattle and tears down the servers of
+// clusters that were removed, deactivated or reconfigured. Reconfigured clusters are rebuilt
+// with their new configuration on the next request.
+func (s *Factory) Watch(ctx context.Context) {
+	go wait.Until(s.syncServers, syncInterval, ctx.Done())
+	go wait.Until(s.hibernateIdle, idleCheckInterval, ctx.Done())
+}
+
+func (s *Factory) syncServers() {
+	s.servers.Range(func(key, value interface{}) bool {
+		s.syncServer(key.(string), value.(*trackedServer))
+		return true
+	})
+}
+
+func (s *Factory) syncServer(clusterID string, server *trackedServer) {
+	if s.membership != nil && !s.membership.Owns(clusterID) {
+		s.remove(clusterID, server, "cluster moved to another replica")
+		return
+	}
+
+	c, err := s.clusterSource.LookupByID(clusterID)
+	if err != nil {
+		logrus.Errorf("Failed to check cluster %s, keeping current server: %v", clusterID, err)
+		return
+	}
+
+	switch {
+	case c == nil || c.Removed != "":
+		s.remove(clusterID, server, "cluster was removed")
+	case inactiveStates.Has(c.State):
+		s.remove(clusterID, server, "cluster is "+c.State)
+	case configChanged(server.Cluster(), clusterConfig(c)):
+		s.remove(clusterID, server, "cluster configuration changed")
+	case !reflect.DeepEqual(server.authorization, s.authorization(c)):
+		s.remove(clusterID, server, "cluster authorization changed")
+	}
+}
+
+// remove stops routing new requests to the server and closes it once its in flight requests
+// have finished or the drain timeout has passed.
+func (s *Factory) remove(clusterID string, server *trackedServer, reason string) {
+	if !s.unregister(clusterID, server) {
+		return
+	}
+
+	logrus.Infof("Stopping server for cluster %s: %s", clusterID, reason)
+	go func() {
+		if !server.drain(drainTimeout) {
+			logrus.Warnf("Closing server for cluster %s with %d requests in flight", clusterID, server.InFlight())
+		}
+		server.Close()
+	}()
+}
+
+// unregister stops routing requests to the server, returning false if it was already replaced
+func (s *Factory) unregister(clusterID string, server *trackedServer) bool {
+	s.removeLock.Lock()
+	defer s.removeLock.Unlock()
+
+	if s.lookupServer(clusterID) != server {
+		return false
+	}
+
+	s.servers.Delete(clusterID)
+	return true
+}
+
+func configChanged(old, new *client.Cluster) bool {
+	return old.Uuid != new.Uuid ||
+		old.Embedded != new.Embedded ||
+		old.K8sServerConfig.ServiceNetCidr != new.K8sServerConfig.ServiceNetCidr ||
+		!reflect.DeepEqual(old.K8sServerConfig.AdmissionControllers, new.K8sServerConfig.AdmissionControllers) ||
+		clientConfig(old) != clientConfig(new)
+}
+
+func clientConfig(c *client.Cluster) [3]string {
+	if c.K8sClientConfig == nil {
+		return [3]string{}
+	}
+	return [3]string{
+		c.K8sClientConfig.Address,
+		c.K8sClientConfig.BearerToken,
+		c.K8sClientConfig.CaCert,
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rancher/netes/server/embedded"
+	"k8s.io/apimachinery/pkg/util/sets"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+var requestInfoResolver = &apirequest.RequestInfoFactory{
+	APIPrefixes:          sets.NewString("api", "apis"),
+	GrouplessAPIPrefixes: sets.NewString("api"),
+}
+
+// RequestInfo parses the request as seen by the apiserver of the cluster
+func RequestInfo(clusterID string, req *http.Request) *apirequest.RequestInfo {
+	clusterReq := *req
+	clusterURL := *req.URL
+	clusterURL.Path = strings.TrimPrefix(clusterURL.Path, "/k8s/clusters/"+clusterID)
+	clusterReq.URL = &clusterURL
+
+	info, err := requestInfoResolver.NewRequestInfo(&clusterReq)
+	if err != nil {
+		return &apirequest.RequestInfo{}
+	}
+	return info
+}
+
+// IsLongRunning returns true for watches, exec sessions and other requests which may never end
+func IsLongRunning(req *http.Request, info *apirequest.RequestInfo) bool {
+	return embedded.LongRunningRequestCheck(req, info)
+}
+
+// longRunningWriter lets netes end a long running request cleanly. Watches end when they are
+// told the client went away, upgraded connections such as exec and port forwarding are closed.
+type longRunningWriter struct {
+	http.ResponseWriter
+	sync.Mutex
+	closeNotify chan bool
+	stop        chan struct{}
+	stopped     bool
+	done        chan struct{}
+	conn        net.Conn
+}
+
+func newLongRunningWriter(rw http.ResponseWriter) *longRunningWriter {
+	w := &longRunningWriter{
+		ResponseWriter: rw,
+		closeNotify:    make(chan bool, 1),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+
+	var clientGone <-chan bool
+	if notifier, ok := rw.(http.CloseNotifier); ok {
+		clientGone = notifier.CloseNotify()
+	}
+
+	go func() {
+		select {
+		case <-clientGone:
+		case <-w.stop:
+		case <-w.done:
+			return
+		}
+		w.closeNotify <- true
+	}()
+
+	return w
+}
+
+func (w *longRunningWriter) CloseNotify() <-chan bool {
+	return w.closeNotify
+}
+
+func (w *longRunningWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *longRunningWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer %T can not be hijacked", w.ResponseWriter)
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return conn, rw, err
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	w.conn = conn
+	if w.stopped {
+		conn.Close()
+	}
+	return conn, rw, err
+}
+
+// shutdown ends the request
+func (w *longRunningWriter) shutdown() {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.stopped {
+		return
+	}
+	w.stopped = true
+	close(w.stop)
+	if w.conn != nil {
+		w.conn.Close()
+	}
+}
+
+// finish releases the resources of the writer once the request is complete
+func (w *longRunningWriter) finish() {
+	close(w.done)
+}
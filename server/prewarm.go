@@ -0,0 +1,84 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Prewarm builds the servers of all active embedded clusters, at most concurrency at a time,
+// so the first requests after a restart of netes do not pay for starting the apiserver.
+func (s *Factory) Prewarm(concurrency int) error {
+	defer atomic.StoreInt32(&s.prewarmed, 1)
+
+	clusters, err := s.clusterSource.List()
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg        sync.WaitGroup
+		semaphore = make(chan struct{}, concurrency)
+		count     int
+	)
+
+	for _, c := range clusters {
+		if !c.Embedded || c.Removed != "" || inactiveStates.Has(c.State) {
+			continue
+		}
+		if s.membership != nil && !s.membership.Owns(c.Id) {
+			continue
+		}
+
+		count++
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(server *trackedServer) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			if err := s.start(server); err != nil {
+				logrus.Errorf("Prewarming cluster %s: %v", server.Cluster().Id, err)
+				return
+			}
+			server.wait()
+
+			state, err := server.State()
+			if err != nil {
+				logrus.Errorf("Prewarming cluster %s: %s: %v", server.Cluster().Id, state, err)
+			} else {
+				logrus.Infof("Prewarming cluster %s: %s", server.Cluster().Id, state)
+			}
+		}(s.newTrackedServer(c))
+	}
+
+	wg.Wait()
+	logrus.Infof("Prewarmed %d clusters", count)
+	return nil
+}
+
+// Prewarmed returns true once Prewarm has finished, successfully or not
+func (s *Factory) Prewarmed() bool {
+	return atomic.LoadInt32(&s.prewarmed) == 1
+}
+
+// ServerState is the state of the server of a cluster, with the error of its last failed build
+type ServerState struct {
+	State State
+	Err   error
+}
+
+// States returns the state of the server of every cluster the factory knows about
+func (s *Factory) States() map[string]ServerState {
+	states := map[string]ServerState{}
+	s.servers.Range(func(key, value interface{}) bool {
+		state, err := value.(*trackedServer).State()
+		states[key.(string)] = ServerState{
+			State: state,
+			Err:   err,
+		}
+		return true
+	})
+	return states
+}
@@ -0,0 +1,127 @@
+package remote
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/netes/cluster"
+	"github.com/rancher/netes/types"
+	genericrest "k8s.io/apiserver/pkg/registry/generic/rest"
+)
+
+type remoteServer struct {
+	cluster   *client.Cluster
+	target    *url.URL
+	transport *http.Transport
+	username  string
+	password  string
+}
+
+func (r *remoteServer) Close() {
+	r.transport.CloseIdleConnections()
+}
+
+func (r *remoteServer) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		c := cluster.GetCluster(req.Context())
+
+		location := *r.target
+		location.Path = strings.TrimSuffix(location.Path, "/") + strings.TrimPrefix(req.URL.Path, "/k8s/clusters/"+c.Id)
+		location.RawQuery = req.URL.RawQuery
+
+		// The caller's Rancher credentials are only meaningful to Cattle, never forward them
+		req.Header.Del("Cookie")
+		req.Header.Del("Authorization")
+		if r.cluster.K8sClientConfig.BearerToken != "" {
+			req.Header.Set("Authorization", "Bearer "+r.cluster.K8sClientConfig.BearerToken)
+		} else if r.username != "" {
+			req.SetBasicAuth(r.username, r.password)
+		}
+
+		handler := genericrest.NewUpgradeAwareProxyHandler(&location, r.transport, false, false, &responder{rw})
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+func (r *remoteServer) Cluster() *client.Cluster {
+	return r.cluster
+}
+
+// New returns a server proxying to the K8sClientConfig address of the cluster. Requests are
+// authenticated with its bearer token, the basic auth credentials of the address, or the client
+// certificate configured in RemoteClientCerts for the cluster.
+func New(config *types.GlobalConfig, cluster *client.Cluster) (*remoteServer, error) {
+	target, err := url.Parse(cluster.K8sClientConfig.Address)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid k8s client address")
+	}
+	if target.Scheme == "" {
+		target, err = url.Parse("https://" + cluster.K8sClientConfig.Address)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid k8s client address")
+		}
+	}
+
+	var username, password string
+	if target.User != nil {
+		username = target.User.Username()
+		password, _ = target.User.Password()
+		target.User = nil
+	}
+
+	clientCert, hasClientCert := config.RemoteClientCerts[cluster.Id]
+	tlsConfig, err := tlsConfig(cluster.K8sClientConfig.CaCert, clientCert, hasClientCert)
+	if err != nil {
+		return nil, err
+	}
+
+	return &remoteServer{
+		cluster:  cluster,
+		target:   target,
+		username: username,
+		password: password,
+		transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
+
+func tlsConfig(caCert string, clientCert types.CertKey, hasClientCert bool) (*tls.Config, error) {
+	if caCert == "" && !hasClientCert {
+		return nil, nil
+	}
+
+	config := &tls.Config{}
+
+	if caCert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(caCert)) {
+			return nil, errors.New("Invalid k8s client CA certificate")
+		}
+		config.RootCAs = pool
+	}
+
+	if hasClientCert {
+		cert, err := tls.LoadX509KeyPair(clientCert.CertFile, clientCert.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "Loading k8s client certificate")
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+type responder struct {
+	rw http.ResponseWriter
+}
+
+func (r *responder) Error(err error) {
+	http.Error(r.rw, err.Error(), http.StatusBadGateway)
+}
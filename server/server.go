@@ -10,3 +10,8 @@ type Server interface {
 	Handler() http.Handler
 	Cluster() *client.Cluster
 }
+
+// HealthChecker is implemented by servers that can check their own health
+type HealthChecker interface {
+	Healthz() error
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// ShutdownLongRunning ends every long running request, such as watches, exec sessions and
+// port forwards, so clients reconnect elsewhere instead of being cut off when netes exits.
+func (s *Factory) ShutdownLongRunning() {
+	s.servers.Range(func(key, value interface{}) bool {
+		value.(*trackedServer).shutdownLongRunning()
+		return true
+	})
+}
+
+// Close closes every server, stopping their informers and controllers
+func (s *Factory) Close() {
+	var wg sync.WaitGroup
+	s.servers.Range(func(key, value interface{}) bool {
+		server := value.(*trackedServer)
+		if !s.unregister(key.(string), server) {
+			return true
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logrus.Infof("Closing server for cluster %s", server.Cluster().Id)
+			server.Close()
+		}()
+		return true
+	})
+	wg.Wait()
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/rancher/go-rancher/v3"
+	"github.com/rancher/netes/status"
+	"github.com/rancher/netes/types"
+)
+
+type State string
+
+const (
+	StateBuilding   State = "building"
+	StateReady      State = "ready"
+	StateFailed     State = "failed"
+	StateBackingOff State = "backing-off"
+
+	initialBackoff = 5 * time.Second
+	maxBackoff     = 5 * time.Minute
+	buildWait      = time.Second
+)
+
+// trackedServer builds the server of a cluster in the background and, once it is ready, counts
+// the requests it is serving so it can be drained before it is closed.
+type trackedServer struct {
+	sync.Mutex
+	cluster *client.Cluster
+	// authorization is the effective authorization of the cluster when the server was created
+	authorization types.AuthorizationConfig
+	server        Server
+	state         State
+	built         chan struct{}
+	err           error
+	failures      int
+	retryAt       time.Time
+	closed        bool
+
+	longRunning map[*longRunningWriter]bool
+
+	created  time.Time
+	lastUsed int64
+	requests int64
+	inflight int64
+	watches  int64
+}
+
+func newTrackedServer(cluster *client.Cluster) *trackedServer {
+	return &trackedServer{
+		cluster:     cluster,
+		state:       StateFailed,
+		created:     time.Now(),
+		lastUsed:    time.Now().UnixNano(),
+		longRunning: map[*longRunningWriter]bool{},
+	}
+}
+
+// start builds the server in the background unless it is already built, being built or the
+// backoff from the previous failure has not passed yet.
+func (t *trackedServer) start(newServer func(*client.Cluster) (Server, error)) {
+	t.Lock()
+	defer t.Unlock()
+
+	if !t.startableLocked() {
+		return
+	}
+
+	t.state = StateBuilding
+	t.built = make(chan struct{})
+	go t.build(newServer, t.built)
+}
+
+// startable returns true if start would build the server
+func (t *trackedServer) startable() bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.startableLocked()
+}
+
+func (t *trackedServer) startableLocked() bool {
+	return !t.closed && t.state == StateFailed && !time.Now().Before(t.retryAt)
+}
+
+// running returns true if the server is built or being built
+func (t *trackedServer) running() bool {
+	t.Lock()
+	defer t.Unlock()
+	return !t.closed && (t.state == StateReady || t.state == StateBuilding)
+}
+
+func (t *trackedServer) build(newServer func(*client.Cluster) (Server, error), built chan struct{}) {
+	defer close(built)
+
+	server, err := newServer(t.cluster)
+	if err == nil && server == nil {
+		err = fmt.Errorf("no server available for cluster")
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	if err != nil {
+		t.failures++
+		wait := backoff(t.failures)
+		t.state = StateFailed
+		t.err = err
+		t.retryAt = time.Now().Add(wait)
+		logrus.Errorf("Failed to start server for cluster %s (attempt %d), retrying in %v: %v",
+			t.cluster.Id, t.failures, wait, err)
+		return
+	}
+
+	if t.closed {
+		server.Close()
+		return
+	}
+
+	logrus.Infof("Started server for cluster %s", t.cluster.Id)
+	t.server = server
+	t.state = StateReady
+	t.err = nil
+	t.failures = 0
+	t.created = time.Now()
+}
+
+// backoff returns how long to wait before building the server again after the given number of
+// consecutive failures, doubling from initialBackoff up to maxBackoff
+func backoff(failures int) time.Duration {
+	if failures < 10 && initialBackoff<<uint(failures-1) < maxBackoff {
+		return initialBackoff << uint(failures-1)
+	}
+	return maxBackoff
+}
+
+// wait blocks until the build in progress, if any, completes
+func (t *trackedServer) wait() {
+	t.Lock()
+	built := t.built
+	t.Unlock()
+
+	if built != nil {
+		<-built
+	}
+}
+
+// State returns the state of the server and the error of the last failed build
+func (t *trackedServer) State() (State, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.state == StateFailed && time.Now().Before(t.retryAt) {
+		return StateBackingOff, t.err
+	}
+	return t.state, t.err
+}
+
+func (t *trackedServer) Close() {
+	t.Lock()
+	defer t.Unlock()
+
+	t.closed = true
+	if t.server != nil {
+		t.server.Close()
+	}
+}
+
+func (t *trackedServer) Cluster() *client.Cluster {
+	return t.cluster
+}
+
+func (t *trackedServer) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		server := t.ready()
+		if server == nil {
+			t.unavailable(rw)
+			return
+		}
+
+		atomic.AddInt64(&t.requests, 1)
+		atomic.AddInt64(&t.inflight, 1)
+		defer func() {
+			atomic.StoreInt64(&t.lastUsed, time.Now().UnixNano())
+			atomic.AddInt64(&t.inflight, -1)
+		}()
+		atomic.StoreInt64(&t.lastUsed, time.Now().UnixNano())
+
+		info := RequestInfo(t.cluster.Id, req)
+		if info.Verb == "watch" {
+			atomic.AddInt64(&t.watches, 1)
+			defer atomic.AddInt64(&t.watches, -1)
+		}
+
+		if IsLongRunning(req, info) {
+			writer := t.addLongRunning(rw)
+			defer t.removeLongRunning(writer)
+			rw = writer
+		}
+
+		server.Handler().ServeHTTP(rw, req)
+	})
+}
+
+// ready returns the server if it is ready, giving a build in progress a moment to complete
+// so that servers which are quick to build never turn clients away.
+func (t *trackedServer) ready() Server {
+	t.Lock()
+	server, built := t.server, t.built
+	t.Unlock()
+
+	if server != nil || built == nil {
+		return server
+	}
+
+	select {
+	case <-built:
+	case <-time.After(buildWait):
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	return t.server
+}
+
+func (t *trackedServer) unavailable(rw http.ResponseWriter) {
+	state, err := t.State()
+
+	retryAfter := 5
+	message := fmt.Sprintf("Cluster %s is starting", t.cluster.Id)
+	if state != StateBuilding {
+		t.Lock()
+		if wait := int(time.Until(t.retryAt).Seconds()) + 1; wait > retryAfter {
+			retryAfter = wait
+		}
+		t.Unlock()
+		message = fmt.Sprintf("Cluster %s failed to start: %v", t.cluster.Id, err)
+	}
+
+	status.Write(rw, status.ServiceUnavailable(message, retryAfter))
+}
+
+func (t *trackedServer) Requests() int64 {
+	return atomic.LoadInt64(&t.requests)
+}
+
+func (t *trackedServer) InFlight() int64 {
+	return atomic.LoadInt64(&t.inflight)
+}
+
+func (t *trackedServer) Watches() int64 {
+	return atomic.LoadInt64(&t.watches)
+}
+
+func (t *trackedServer) LastUsed() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&t.lastUsed))
+}
+
+// drain waits for in flight requests to finish, returning false if they did not finish in time
+func (t *trackedServer) drain(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for t.InFlight() > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return true
+}
+
+func (t *trackedServer) addLongRunning(rw http.ResponseWriter) *longRunningWriter {
+	writer := newLongRunningWriter(rw)
+
+	t.Lock()
+	defer t.Unlock()
+	t.longRunning[writer] = true
+	return writer
+}
+
+func (t *trackedServer) removeLongRunning(writer *longRunningWriter) {
+	writer.finish()
+
+	t.Lock()
+	defer t.Unlock()
+	delete(t.longRunning, writer)
+}
+
+// shutdownLongRunning ends all long running requests such as watches and exec sessions
+func (t *trackedServer) shutdownLongRunning() {
+	t.Lock()
+	defer t.Unlock()
+
+	for writer := range t.longRunning {
+		writer.shutdown()
+	}
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rancher/go-rancher/v3"
+)
+
+type fakeServer struct {
+	cluster *client.Cluster
+	closed  bool
+}
+
+func (f *fakeServer) Close() {
+	f.closed = true
+}
+
+func (f *fakeServer) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+}
+
+func (f *fakeServer) Cluster() *client.Cluster {
+	return f.cluster
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{6, 160 * time.Second},
+		{7, 5 * time.Minute},
+		{10, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+
+	for _, test := range tests {
+		if actual := backoff(test.failures); actual != test.expected {
+			t.Errorf("backoff(%d) = %v, expected %v", test.failures, actual, test.expected)
+		}
+	}
+}
+
+func TestTrackedServerStates(t *testing.T) {
+	buildErr := errors.New("build failed")
+
+	tests := []struct {
+		name        string
+		builds      []error
+		retryPassed bool
+		closed      bool
+		state       State
+		failures    int
+		startable   bool
+		running     bool
+	}{
+		{
+			name:      "new",
+			state:     StateFailed,
+			startable: true,
+		},
+		{
+			name:    "built",
+			builds:  []error{nil},
+			state:   StateReady,
+			running: true,
+		},
+		{
+			name:     "failed",
+			builds:   []error{buildErr},
+			state:    StateBackingOff,
+			failures: 1,
+		},
+		{
+			name:        "failed and backoff passed",
+			builds:      []error{buildErr},
+			retryPassed: true,
+			state:       StateFailed,
+			failures:    1,
+			startable:   true,
+		},
+		{
+			name:        "failed twice",
+			builds:      []error{buildErr, buildErr},
+			retryPassed: true,
+			state:       StateFailed,
+			failures:    2,
+			startable:   true,
+		},
+		{
+			name:        "built after failing",
+			builds:      []error{buildErr, nil},
+			retryPassed: true,
+			state:       StateReady,
+			running:     true,
+		},
+		{
+			name:   "closed",
+			closed: true,
+			state:  StateFailed,
+		},
+	}
+
+	for _, test := range tests {
+		c := &client.Cluster{}
+		c.Id = "c1"
+		server := newTrackedServer(c)
+		if test.closed {
+			server.Close()
+		}
+
+		for _, buildErr := range test.builds {
+			server.Lock()
+			server.retryAt = time.Time{}
+			server.Unlock()
+
+			err := buildErr
+			server.start(func(c *client.Cluster) (Server, error) {
+				if err != nil {
+					return nil, err
+				}
+				return &fakeServer{cluster: c}, nil
+			})
+			server.wait()
+		}
+
+		if test.retryPassed {
+			server.Lock()
+			server.retryAt = time.Now().Add(-time.Second)
+			server.Unlock()
+		}
+
+		state, _ := server.State()
+		if state != test.state {
+			t.Errorf("%s: state %s, expected %s", test.name, state, test.state)
+		}
+		if server.failures != test.failures {
+			t.Errorf("%s: %d failures, expected %d", test.name, server.failures, test.failures)
+		}
+		if server.startable() != test.startable {
+			t.Errorf("%s: startable %v, expected %v", test.name, server.startable(), test.startable)
+		}
+		if server.running() != test.running {
+			t.Errorf("%s: running %v, expected %v", test.name, server.running(), test.running)
+		}
+	}
+}
+
+func TestTrackedServerUnavailable(t *testing.T) {
+	c := &client.Cluster{}
+	c.Id = "c1"
+	server := newTrackedServer(c)
+	server.start(func(c *client.Cluster) (Server, error) {
+		return nil, errors.New("build failed")
+	})
+	server.wait()
+
+	rw := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/k8s/clusters/c1/api", nil))
+
+	if rw.Code != http.StatusServiceUnavailable {
+		t.Errorf("responded %d, expected %d", rw.Code, http.StatusServiceUnavailable)
+	}
+	if rw.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After to be set")
+	}
+}
+
+func TestTrackedServerClosedWhileBuilding(t *testing.T) {
+	c := &client.Cluster{}
+	c.Id = "c1"
+	server := newTrackedServer(c)
+
+	built := &fakeServer{cluster: c}
+	release := make(chan struct{})
+	server.start(func(c *client.Cluster) (Server, error) {
+		<-release
+		return built, nil
+	})
+
+	server.Close()
+	close(release)
+	server.wait()
+
+	if !built.closed {
+		t.Error("expected server built after close to be closed")
+	}
+	if server.running() {
+		t.Error("expected closed server to not be running")
+	}
+}
@@ -0,0 +1,78 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Write renders err as a Kubernetes Status object, setting Retry-After when the error
+// carries a retry hint.
+func Write(rw http.ResponseWriter, err *apierrors.StatusError) {
+	status := err.ErrStatus
+	status.TypeMeta = metav1.TypeMeta{
+		Kind:       "Status",
+		APIVersion: "v1",
+	}
+
+	if status.Details != nil && status.Details.RetryAfterSeconds > 0 {
+		rw.Header().Set("Retry-After", strconv.Itoa(int(status.Details.RetryAfterSeconds)))
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(int(status.Code))
+	json.NewEncoder(rw).Encode(&status)
+}
+
+// reasonTooManyRequests is the reason later Kubernetes versions report for 429 responses
+const reasonTooManyRequests metav1.StatusReason = "TooManyRequests"
+
+// New returns an error with the given HTTP status code and a matching reason
+func New(code int, message string) *apierrors.StatusError {
+	reason := metav1.StatusReasonUnknown
+	switch code {
+	case http.StatusBadRequest:
+		reason = metav1.StatusReasonBadRequest
+	case http.StatusUnauthorized:
+		reason = metav1.StatusReasonUnauthorized
+	case http.StatusForbidden:
+		reason = metav1.StatusReasonForbidden
+	case http.StatusNotFound:
+		reason = metav1.StatusReasonNotFound
+	case http.StatusInternalServerError:
+		reason = metav1.StatusReasonInternalError
+	case http.StatusTooManyRequests:
+		reason = reasonTooManyRequests
+	case http.StatusServiceUnavailable:
+		reason = metav1.StatusReasonServiceUnavailable
+	}
+
+	return &apierrors.StatusError{
+		ErrStatus: metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    int32(code),
+			Reason:  reason,
+			Message: message,
+		},
+	}
+}
+
+// ServiceUnavailable returns a 503 error asking the client to retry after the given delay
+func ServiceUnavailable(message string, retryAfterSeconds int) *apierrors.StatusError {
+	err := apierrors.NewServiceUnavailable(message)
+	err.ErrStatus.Details = &metav1.StatusDetails{
+		RetryAfterSeconds: int32(retryAfterSeconds),
+	}
+	return err
+}
+
+// TooManyRequests returns a 429 error asking the client to retry after the given delay
+func TooManyRequests(message string, retryAfterSeconds int) *apierrors.StatusError {
+	err := New(http.StatusTooManyRequests, message)
+	err.ErrStatus.Details = &metav1.StatusDetails{
+		RetryAfterSeconds: int32(retryAfterSeconds),
+	}
+	return err
+}
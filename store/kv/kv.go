@@ -0,0 +1,123 @@
+package kv
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/k8s-sql"
+	"github.com/rancher/k8s-sql/dialect"
+	"github.com/rancher/k8s-sql/dialect/mysql"
+	sqlkv "github.com/rancher/k8s-sql/kv"
+)
+
+const maxPutAttempts = 10
+
+// ErrExists is returned by Create if the key already exists
+var ErrExists = sqlkv.ErrExists
+
+var dialects = map[string]func() *dialect.Generic{
+	"mysql": mysql.NewMySQL,
+}
+
+// Store keeps netes' own state in the key_value table shared with the Kubernetes storage, using
+// the same k8s-sql dialect and revisions. Keys written through Store should be outside of the
+// prefixes used for cluster storage.
+type Store struct {
+	db      *sql.DB
+	dialect *dialect.Generic
+}
+
+func New(dialectName, dsn string) (*Store, error) {
+	newDialect, ok := dialects[dialectName]
+	if !ok {
+		return nil, fmt.Errorf("Failed to find dialect %v", dialectName)
+	}
+
+	db, err := sql.Open(dialectName, dsn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to create DB(%s) connection", dialectName)
+	}
+
+	return &Store{
+		db:      db,
+		dialect: newDialect(),
+	}, nil
+}
+
+// Ping checks that the key_value table can be read
+func (s *Store) Ping(ctx context.Context) error {
+	_, err := s.Get(ctx, "/netes/ping")
+	return err
+}
+
+// Get returns the value of key, or nil if it does not exist
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.dialect.Get(ctx, s.db, key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return value.Value, nil
+}
+
+// List returns the values of all keys starting with prefix
+func (s *Store) List(ctx context.Context, prefix string) (map[string][]byte, error) {
+	values, err := s.dialect.List(ctx, s.db, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string][]byte{}
+	for _, value := range values {
+		result[value.Key] = value.Value
+	}
+	return result, nil
+}
+
+// Create sets the value of key, returning ErrExists if it already has one
+func (s *Store) Create(ctx context.Context, key string, value []byte) error {
+	err := s.dialect.Create(ctx, s.db, key, value, 0)
+	if err == nil {
+		return nil
+	}
+
+	if existing, getErr := s.dialect.Get(ctx, s.db, key); getErr == nil && existing != nil {
+		return ErrExists
+	}
+	return err
+}
+
+// Put creates or replaces the value of key. Concurrent writers are resolved with the revision
+// of the key, the last one to write wins.
+func (s *Store) Put(ctx context.Context, key string, value []byte) error {
+	for i := 0; i < maxPutAttempts; i++ {
+		existing, err := s.dialect.Get(ctx, s.db, key)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			err = s.Create(ctx, key, value)
+		} else {
+			_, _, err = s.dialect.Update(ctx, s.db, key, value, existing.Revision)
+		}
+
+		switch err {
+		case ErrExists, sqlkv.ErrNotExists, rdbms.ErrRevisionMatch:
+			continue
+		}
+		return err
+	}
+
+	return fmt.Errorf("too many concurrent writes to %s", key)
+}
+
+// Delete deletes key, if it exists
+func (s *Store) Delete(ctx context.Context, key string) error {
+	_, err := s.dialect.Delete(ctx, s.db, key, nil)
+	if err == sqlkv.ErrNotExists {
+		return nil
+	}
+	return err
+}
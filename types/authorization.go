@@ -0,0 +1,86 @@
+package types
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// AuthorizationFile is the authorization of clusters read from a YAML or JSON file. The file is
+// reloaded whenever it changes, the servers of clusters whose authorization changed are rebuilt.
+// If the file can no longer be read the authorization last read is kept.
+//
+//	modes: [Node, RBAC]
+//	clusters:
+//	  1c1:
+//	    modes: [AlwaysAllow]
+//	  1c2:
+//	    modes: [Node, RBAC, Webhook]
+//	    webhookURL: https://authz.example.com/v1/subjectaccessreviews
+type AuthorizationFile struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	config  authorizationFileConfig
+}
+
+type authorizationFileConfig struct {
+	// Modes are the authorizers of clusters which are not listed in Clusters
+	Modes    []string                       `json:"modes,omitempty"`
+	Clusters map[string]AuthorizationConfig `json:"clusters,omitempty"`
+}
+
+func NewAuthorizationFile(path string) (*AuthorizationFile, error) {
+	f := &AuthorizationFile{
+		path: path,
+	}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Get returns the modes of clusters without their own authorization and the authorization of
+// the clusters listed in the file
+func (f *AuthorizationFile) Get() ([]string, map[string]AuthorizationConfig) {
+	if err := f.load(); err != nil {
+		logrus.Errorf("Keeping the authorization last read: %v", err)
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	return f.config.Modes, f.config.Clusters
+}
+
+func (f *AuthorizationFile) load() error {
+	f.Lock()
+	defer f.Unlock()
+
+	stat, err := os.Stat(f.path)
+	if err != nil {
+		return errors.Wrapf(err, "Reading authorization file %s", f.path)
+	}
+
+	if !f.modTime.IsZero() && stat.ModTime().Equal(f.modTime) {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return errors.Wrapf(err, "Reading authorization file %s", f.path)
+	}
+
+	config := authorizationFileConfig{}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return errors.Wrapf(err, "Parsing authorization file %s", f.path)
+	}
+
+	f.config = config
+	f.modTime = stat.ModTime()
+	return nil
+}
@@ -1,17 +1,172 @@
 package types
 
-import "github.com/rancher/netes/cluster"
+import (
+	"time"
+
+	"github.com/rancher/netes/cluster"
+	"github.com/rancher/netes/membership"
+	"github.com/rancher/netes/store/kv"
+)
 
 type GlobalConfig struct {
 	Dialect    string
 	DSN        string
 	CattleURL  string
 	ListenAddr string
+	// TLS, if set, serves HTTPS instead of HTTP on ListenAddr
+	TLS *TLSConfig
+
+	// ClusterDomain, if set, routes requests for <id>.<ClusterDomain> to cluster <id>
+	ClusterDomain string
+	// ClusterHosts routes requests for the given host names to the mapped cluster ids
+	ClusterHosts map[string]string
+
+	// RemoteClientCerts maps cluster ids to the client certificate presented to the
+	// K8sClientConfig address of the cluster, for clusters not using a bearer token
+	RemoteClientCerts map[string]CertKey
+
+	// Limits caps the requests served for every cluster, ClusterLimits replaces them for the
+	// given cluster ids
+	Limits        LimitConfig
+	ClusterLimits map[string]LimitConfig
+
+	// ShutdownTimeout is how long requests in flight may take to finish when netes is stopped
+	ShutdownTimeout time.Duration
+
+	// AdminToken is the bearer token of the admin API under /netes/v1/, the API is disabled
+	// if it is not set
+	AdminToken string
+
+	CattleAccessKey string
+	CattleSecretKey string
+	// GroupPrefix is prepended to the groups of Rancher users, such as rancher:
+	GroupPrefix string
+	// RoleGroups maps the environment roles of Rancher users (owner, member, read-only and
+	// restricted) to Kubernetes groups. Only these mappings can grant system: groups such as
+	// system:masters.
+	RoleGroups map[string][]string
+	// TokenCacheTTL is how long credentials checked with Cattle are trusted before they are
+	// checked again, defaults to 30 seconds. A revoked API key or token keeps working for up to
+	// this long, or for up to CattleGracePeriod while Cattle is unavailable.
+	TokenCacheTTL time.Duration
 
 	AdmissionControllers []string
 	ServiceNetCidr       string
+	// AuthorizationModes are the authorizers of clusters which do not configure their own,
+	// defaults to Node and RBAC. AlwaysAllow can only be enabled by the config of a cluster.
+	AuthorizationModes []string
+	// ClusterAuthorization replaces the authorization of the given cluster ids
+	ClusterAuthorization map[string]AuthorizationConfig
+	// AuthorizationFile, if set, is read for the authorization of clusters instead of
+	// AuthorizationModes and ClusterAuthorization
+	AuthorizationFile *AuthorizationFile
+	// WebhookAllowTTL and WebhookDenyTTL are how long the decisions of the authorization webhook
+	// are cached, defaulting to 5 minutes and 30 seconds
+	WebhookAllowTTL time.Duration
+	WebhookDenyTTL  time.Duration
+
+	// IdleTimeout is how long an embedded server may go unused before it is shut down, zero
+	// keeps servers running forever
+	IdleTimeout time.Duration
+	// MaxEmbeddedServers caps the number of embedded servers running at once, zero is unlimited
+	MaxEmbeddedServers int
+	// PrewarmConcurrency is how many embedded servers to build in parallel at startup, zero
+	// skips building servers before they are first requested
+	PrewarmConcurrency int
+
+	// ClustersFile, if set, is a YAML or JSON file of cluster definitions used instead of Cattle
+	ClustersFile  string
+	ClusterSource cluster.ClusterSource
+	// Members, if set, lists the Rancher environment members bound to roles in embedded clusters
+	Members cluster.MemberLister
+	// MemberRoles maps the environment roles of members to the cluster roles they are bound to,
+	// defaults to admin for owners, edit for members and view for restricted and read-only members
+	MemberRoles map[string]string
+	// CattleGracePeriod is how long a caller keeps access to a cluster after its last
+	// successful lookup while Cattle is unavailable, zero disables the fallback
+	CattleGracePeriod time.Duration
+
+	// PeerAddress, if set, is the URL other netes replicas use to reach this one. Clusters are
+	// then sharded between all replicas sharing the database.
+	PeerAddress string
+	PeerID      string
+	Membership  *membership.Membership
+
+	// Store keeps the state netes shares between replicas and restarts
+	Store *kv.Store
+}
+
+type LimitConfig struct {
+	// Cluster limits the requests of all users of a cluster together
+	Cluster RequestLimits
+	// User limits the requests of each user of a cluster
+	User RequestLimits
+}
+
+// RequestLimits has separate budgets for requests changing the cluster, requests only reading
+// it and long running requests such as watches and exec sessions
+type RequestLimits struct {
+	Mutating    Limit
+	ReadOnly    Limit
+	LongRunning Limit
+}
+
+// Limit caps the rate of requests and how many may be served at once, zero values are unlimited
+type Limit struct {
+	QPS         float32
+	Burst       int
+	MaxInFlight int
+}
+
+// LimitsFor returns the limits configured for the given cluster
+func (c *GlobalConfig) LimitsFor(clusterID string) LimitConfig {
+	if limits, ok := c.ClusterLimits[clusterID]; ok {
+		return limits
+	}
+	return c.Limits
+}
+
+// AuthorizationConfig is the authorization of a cluster
+type AuthorizationConfig struct {
+	// Modes are the authorizers of the cluster, in order, such as Node, RBAC, Webhook and
+	// AlwaysAllow
+	Modes []string `json:"modes,omitempty"`
+	// WebhookURL, if set, is sent the SubjectAccessReviews of the Webhook mode instead of Cattle
+	WebhookURL string `json:"webhookURL,omitempty"`
+}
+
+// AuthorizationFor returns the authorization configured for the given cluster and whether it
+// was configured for the cluster rather than for every cluster
+func (c *GlobalConfig) AuthorizationFor(clusterID string) (AuthorizationConfig, bool) {
+	modes, clusters := c.AuthorizationModes, c.ClusterAuthorization
+	if c.AuthorizationFile != nil {
+		modes, clusters = c.AuthorizationFile.Get()
+	}
+
+	if authorization, ok := clusters[clusterID]; ok && len(authorization.Modes) > 0 {
+		return authorization, true
+	}
+	return AuthorizationConfig{
+		Modes: modes,
+	}, false
+}
+
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// SNICerts maps server names, which may be wildcards such as *.k8s.example.com, to the
+	// certificate served for them instead of the default certificate
+	SNICerts map[string]CertKey
+	// CAFile is the CA bundle put in generated kubeconfigs, CertFile is used if it is not set
+	CAFile string
+	// ClientCAFile, if set, requests optional client certificates and authenticates callers
+	// presenting one signed by these CAs
+	ClientCAFile string
+}
 
-	Lookup *cluster.Lookup
+type CertKey struct {
+	CertFile string
+	KeyFile  string
 }
 
 func FirstNotEmpty(left, right string) string {